@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"npgo/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Clean install exactly what the lockfile records",
+	Long: `CI checks every direct dependency in package.json (and in each workspace)
+against .npgo-lock.yaml, removes node_modules and installs exactly the locked
+graph. It never re-resolves or writes the lockfile: if anything has drifted it
+prints what is out of date and exits 1.
+
+Equivalent to 'npgo install --frozen-lockfile' on a clean node_modules.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ui.Logo()
+		applyRegistryConfig()
+		frozenLockfile = true
+		cleanInstall = true
+		installFromPackageJSON()
+	},
+}
+
+func init() {
+	ciCmd.Flags().BoolVarP(&devFlag, "dev", "D", false, "include devDependencies and print debug logs")
+	ciCmd.Flags().IntVarP(&resolveConcurrency, "concurrency", "c", 0, "download concurrency (0=auto)")
+	ciCmd.Flags().BoolVar(&strictDeprecations, "strict-deprecations", false, "fail when a direct dependency is deprecated")
+	ciCmd.Flags().BoolVar(&engineStrict, "engine-strict", false, "fail instead of warning when a package's engines.node excludes the local node")
+	ciCmd.Flags().BoolVar(&offlineFlag, "offline", false, "install only from the registry cache and the store; never use the network")
+	ciCmd.Flags().BoolVar(&preferOfflineFlag, "prefer-offline", false, "use fresh cached packuments without revalidating them")
+	rootCmd.AddCommand(ciCmd)
+}
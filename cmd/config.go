@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+
+	"npgo/internal/registry"
 
 	"github.com/spf13/cobra"
 )
 
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "Show npgo config paths",
+	Short: "Show npgo config paths and registries",
 	Run: func(cmd *cobra.Command, args []string) {
 		home, _ := os.UserHomeDir()
 		cacheDir := filepath.Join(home, ".npgo")
@@ -21,6 +24,20 @@ var configCmd = &cobra.Command{
 		fmt.Println("  Extracted:", filepath.Join(cacheDir, "extracted"))
 		fmt.Println("  CAS Store:", filepath.Join(cacheDir, "store", "v3"))
 		fmt.Println("  Global node_modules:", filepath.Join(cacheDir, "node_modules"))
+
+		cfg := loadRegistries()
+		fmt.Println("  Registry:", registry.Default.URL)
+		scopes := make([]string, 0, len(registry.Default.Scopes))
+		for scope := range registry.Default.Scopes {
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		for _, scope := range scopes {
+			fmt.Printf("  Registry %s: %s\n", scope, registry.Default.Scopes[scope])
+		}
+		if cfg.Bool("offline") {
+			fmt.Println("  Offline: true")
+		}
 	},
 }
 
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"npgo/internal/lockfile"
+	"npgo/internal/packagejson"
+	"npgo/internal/resolver"
+	"npgo/internal/ui"
+	"npgo/internal/workspace"
+
+	"github.com/spf13/cobra"
+)
+
+var dedupeCheck bool
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Reduce duplicate package versions in the lockfile",
+	Long: `Dedupe loads the graph recorded in .npgo-lock.yaml, collapses compatible
+ranges onto a single version already in it and rewrites the lockfile with the
+minimal set. It makes no registry requests; run 'npgo install' first if
+package.json has changed.
+
+Examples:
+  npgo dedupe
+  npgo dedupe --check   # exit 1 if the lockfile is not deduplicated (CI)`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		applyRegistryConfig()
+		runDedupe()
+	},
+}
+
+func init() {
+	dedupeCmd.Flags().BoolVar(&dedupeCheck, "check", false, "report duplicates without writing; exit 1 if the lockfile would change")
+	dedupeCmd.Flags().BoolVarP(&devFlag, "dev", "D", false, "print debug logs")
+	rootCmd.AddCommand(dedupeCmd)
+}
+
+func runDedupe() {
+	ui.PrintHeader("Dedupe")
+
+	pkg, err := packagejson.Read("package.json")
+	if err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+
+	members, err := workspace.Discover(".", pkg)
+	if err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	res, err := newProjectResolver(pkg, nil)
+	if err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	res.SetWorkspaces(members)
+	current, err := lockfile.Load(".")
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("%s not found (run 'npgo install' to create it)", lockfile.Path("."))
+		}
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	directSpecs := projectRootSpecs(pkg)
+	if _, err := res.LoadLockfile(current, directSpecs, "."); err != nil {
+		if errors.Is(err, resolver.ErrLockfileStale) {
+			err = fmt.Errorf("%w (run 'npgo install' first)", err)
+		}
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	graph := res.Dedupe(res.WithWorkspaces(directSpecs))
+	order, err := resolver.TopoOrder(graph)
+	if err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	lf := buildLockfile(directSpecs, members, res, order, nil)
+	entries := lf.Packages
+
+	removed, added := diffLockEntries(current.Packages, entries)
+	if len(removed) == 0 && len(added) == 0 {
+		ui.InstallStep("✅", fmt.Sprintf("Lockfile is already deduplicated (%d packages)", len(entries)))
+		return
+	}
+	for _, e := range removed {
+		ui.Error.Printf("  - %s\n", e)
+	}
+	for _, e := range added {
+		ui.Success.Printf("  + %s\n", e)
+	}
+	if dedupeCheck {
+		ui.ErrorMessage(fmt.Errorf("lockfile is not deduplicated: %d to remove, %d to add (run 'npgo dedupe')", len(removed), len(added)))
+		os.Exit(1)
+	}
+	if err := lockfile.Save(".", lf); err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	ui.InstallStep("✅", fmt.Sprintf("Lockfile rewritten: %d packages (%d removed)", len(entries), len(current.Packages)-len(entries)))
+}
+
+// diffLockEntries compares lockfile entries by name@version
+func diffLockEntries(before, after []lockfile.PackageEntry) (removed, added []string) {
+	set := func(entries []lockfile.PackageEntry) map[string]bool {
+		m := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			m[e.Name+"@"+e.Version] = true
+		}
+		return m
+	}
+	b, a := set(before), set(after)
+	for k := range b {
+		if !a[k] {
+			removed = append(removed, k)
+		}
+	}
+	for k := range a {
+		if !b[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added
+}
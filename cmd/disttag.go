@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"npgo/internal/registry"
+	"npgo/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var distTagCmd = &cobra.Command{
+	Use:   "dist-tag",
+	Short: "Inspect package dist-tags",
+}
+
+var distTagLsCmd = &cobra.Command{
+	Use:   "ls <package>",
+	Short: "List the dist-tags of a package",
+	Long: `List prints every dist-tag of a package and the version it points to.
+
+Examples:
+  npgo dist-tag ls typescript
+  npgo dist-tag ls @types/node`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadRegistries()
+		tags, err := registry.DistTags(args[0])
+		if err != nil {
+			ui.ErrorMessage(err)
+			os.Exit(1)
+		}
+		names := make([]string, 0, len(tags))
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		sort.Strings(names)
+		for _, tag := range names {
+			fmt.Printf("%s: %s\n", tag, tags[tag])
+		}
+	},
+}
+
+func init() {
+	distTagCmd.AddCommand(distTagLsCmd)
+	rootCmd.AddCommand(distTagCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"npgo/internal/cache"
@@ -26,6 +27,7 @@ Examples:
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		pkgSpec := args[0]
+		loadRegistries()
 
 		// Show logo and start
 		ui.Logo()
@@ -116,13 +118,18 @@ func init() {
 }
 
 func parsePackageSpec(spec string) (name, version string, err error) {
-	// Simple parser for package@version format
-	for i, char := range spec {
-		if char == '@' {
-			name = spec[:i]
-			version = spec[i+1:]
-			return name, version, nil
+	// name@version, where name may be scoped (@scope/pkg) and version may be
+	// an exact version, a range or a dist-tag such as "next"
+	at := strings.LastIndex(spec, "@")
+	if at <= 0 {
+		if spec == "" || spec == "@" {
+			return "", "", fmt.Errorf("invalid package spec %q", spec)
 		}
+		return spec, "latest", nil
 	}
-	return spec, "latest", nil
+	name, version = spec[:at], spec[at+1:]
+	if version == "" {
+		version = "latest"
+	}
+	return name, version, nil
 }
@@ -1,18 +1,26 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"npgo/internal/cas"
+	"npgo/internal/config"
 	"npgo/internal/installer"
 	"npgo/internal/lockfile"
 	"npgo/internal/packagejson"
+	"npgo/internal/platform"
 	"npgo/internal/registry"
 	"npgo/internal/resolver"
 	"npgo/internal/ui"
+	"npgo/internal/workspace"
 
 	"github.com/spf13/cobra"
 )
@@ -27,10 +35,12 @@ If no package is specified, it installs dependencies from package.json.
 Examples:
   npgo install express
   npgo install react@18.3.1
+  npgo install typescript@next   # any dist-tag
   npgo install             # Install from package.json`,
 	Args: cobra.MinimumNArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
 		ui.Logo()
+		applyRegistryConfig()
 
 		if len(args) > 0 {
 			installSinglePackage(args[0])
@@ -43,10 +53,33 @@ Examples:
 
 var devFlag bool
 var resolveConcurrency int
+var autoInstallPeers bool
+var strictPeerDeps bool
+var frozenLockfile bool
+var strictDeprecations bool
+var engineStrict bool
+
+// projectConfig is the .npmrc configuration loaded by applyRegistryConfig
+var projectConfig *config.Config
+
+var offlineFlag bool
+var preferOfflineFlag bool
+var beforeFlag string
+
+// cleanInstall removes node_modules once the locked graph is loaded (npgo ci)
+var cleanInstall bool
 
 func init() {
 	installCmd.Flags().BoolVarP(&devFlag, "dev", "D", false, "Install as dev dependency")
 	installCmd.Flags().IntVarP(&resolveConcurrency, "concurrency", "c", 0, "resolver concurrency (0=auto)")
+	installCmd.Flags().BoolVar(&autoInstallPeers, "auto-install-peers", true, "install missing required peer dependencies")
+	installCmd.Flags().BoolVar(&strictPeerDeps, "strict-peer-deps", false, "fail when peer dependencies are missing or out of range")
+	installCmd.Flags().BoolVar(&frozenLockfile, "frozen-lockfile", false, "install exactly what the lockfile records; fail if package.json has drifted")
+	installCmd.Flags().BoolVar(&strictDeprecations, "strict-deprecations", false, "fail when a direct dependency is deprecated")
+	installCmd.Flags().BoolVar(&engineStrict, "engine-strict", false, "fail instead of warning when a package's engines.node excludes the local node")
+	installCmd.Flags().BoolVar(&offlineFlag, "offline", false, "resolve only from the registry cache and the store; never use the network")
+	installCmd.Flags().BoolVar(&preferOfflineFlag, "prefer-offline", false, "use fresh cached packuments without revalidating them")
+	installCmd.Flags().StringVar(&beforeFlag, "before", "", "ignore versions published after this date (YYYY-MM-DD or RFC 3339)")
 	rootCmd.AddCommand(installCmd)
 
 }
@@ -102,7 +135,13 @@ func installFromPackageJSON() {
 		os.Exit(1)
 	}
 
-	if !pkg.HasDependencies() {
+	members, err := workspace.Discover(".", pkg)
+	if err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+
+	if !pkg.HasDependencies() && len(members) == 0 && !frozenLockfile {
 		ui.Info.Println("✅ No dependencies to install")
 		fmt.Println()
 		return
@@ -111,12 +150,17 @@ func installFromPackageJSON() {
 	startTime := time.Now()
 
 	ui.InstallStep("📋", fmt.Sprintf("Found %d dependencies to install", len(pkg.GetDependencies())))
-
-	if resolveConcurrency == 0 {
-		resolveConcurrency = autoConcurrency()
+	if len(members) > 0 {
+		ui.InstallStep("🗂️", fmt.Sprintf("Found %d workspace packages", len(members)))
 	}
+
 	var resolvedCount int32
-	res := resolver.NewResolverWithOptions(devFlag, resolveConcurrency, func(_ string) { atomic.AddInt32(&resolvedCount, 1) })
+	res, err := newProjectResolver(pkg, func(_ string) { atomic.AddInt32(&resolvedCount, 1) })
+	if err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	res.SetWorkspaces(members)
 	spinner := ui.NewSpinner("Resolving dependencies...")
 	spinner.Start()
 	stopCh := make(chan struct{})
@@ -125,30 +169,77 @@ func installFromPackageJSON() {
 		ui.InstallStep("🛠️", "--dev enabled: verbose debug logs active")
 		ui.InstallStep("🧩", fmt.Sprintf("Dependencies: %d, DevDependencies: %d", len(pkg.Dependencies), len(pkg.DevDependencies)))
 	}
-	rootSpecs := pkg.Dependencies
-	if devFlag {
-		rootSpecs = pkg.GetDependencies()
+	directSpecs := projectRootSpecs(pkg)
+	rootSpecs := res.WithWorkspaces(directSpecs)
+	var graph map[string]*resolver.Dependency
+	fromLock := false
+	if frozenLockfile {
+		graph, err = loadFrozenGraph(res, directSpecs)
+		if err != nil {
+			spinner.Stop()
+			ui.ErrorMessage(err)
+			os.Exit(1)
+		}
+		fromLock = true
+	} else {
+		graph, fromLock, err = loadLockedGraph(res, directSpecs)
+		if err != nil {
+			spinner.Stop()
+			ui.ErrorMessage(err)
+			os.Exit(1)
+		}
 	}
-	names := make([]string, 0, len(rootSpecs))
-	for n := range rootSpecs {
-		names = append(names, n)
+	if !fromLock {
+		names := make([]string, 0, len(rootSpecs))
+		for n := range rootSpecs {
+			names = append(names, n)
+		}
+		go registry.PrefetchRegistry(names, resolveConcurrency)
+		graph, err = res.BuildGraph(rootSpecs)
+		if err != nil {
+			spinner.Stop()
+			close(stopCh)
+			ui.ErrorMessage(err)
+			os.Exit(1)
+		}
 	}
-	go registry.PrefetchRegistry(names, resolveConcurrency)
-	graph, err := res.BuildGraph(rootSpecs)
+	order, err := resolver.TopoOrder(graph)
 	if err != nil {
 		spinner.Stop()
-		close(stopCh)
 		ui.ErrorMessage(err)
 		os.Exit(1)
 	}
-	order, err := resolver.TopoOrder(graph)
-	if err != nil {
-		spinner.Stop()
+	spinner.Stop()
+	if fromLock {
+		ui.InstallStep("🔒", fmt.Sprintf("Using %s (%d packages, resolution skipped)", lockfile.Path("."), len(order)))
+	} else {
+		ui.InstallStep("✅", "Dependencies resolved (topo ordered)")
+	}
+	for _, w := range res.Warnings() {
+		ui.Warning.Printf("⚠️  %s\n", w)
+	}
+	for _, issue := range res.PeerIssues() {
+		ui.Warning.Printf("⚠️  %s\n", issue)
+	}
+	for _, cycle := range resolver.Cycles(graph) {
+		members := make([]string, len(cycle))
+		for i, d := range cycle {
+			members[i] = d.Name + "@" + d.Resolved
+		}
+		ui.InstallStep("🔁", "Dependency cycle: "+strings.Join(members, " ↔ "))
+	}
+	// everything is locked; devDependencies are only installed with --dev
+	installRoots := res.WithWorkspaces(installSpecs(pkg, directSpecs))
+	pkgs := installer.PlanLayout(installRoots, res.Lookup)
+	installed := installedOrder(order, pkgs)
+	if err := reportDeprecations(res.Deprecations(installRoots)); err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+	if err := checkEngines(pkg, installed); err != nil {
 		ui.ErrorMessage(err)
 		os.Exit(1)
 	}
-	spinner.Stop()
-	ui.InstallStep("✅", "Dependencies resolved (topo ordered)")
 	if devFlag {
 		ui.InstallStep("🔎", "Resolved packages:")
 		for _, d := range order {
@@ -156,11 +247,20 @@ func installFromPackageJSON() {
 		}
 	}
 
+	if cleanInstall {
+		if err := os.RemoveAll("node_modules"); err != nil {
+			ui.ErrorMessage(fmt.Errorf("failed to remove node_modules: %w", err))
+			os.Exit(1)
+		}
+	}
 	inst := installer.NewInstallerWithDebug("./node_modules", devFlag)
 
-	pkgs := make([]installer.PackageSpec, 0, len(order))
-	for _, d := range order {
-		pkgs = append(pkgs, installer.PackageSpec{Name: d.Name, Version: d.Resolved, TarballURL: d.TarballURL})
+	if devFlag {
+		for _, p := range pkgs {
+			if p.Path != p.Name {
+				ui.Muted.Printf("   nested: node_modules/%s (%s)\n", p.Path, p.Version)
+			}
+		}
 	}
 	instSpinner := ui.NewSpinner("Installing packages (pipeline)...")
 	instSpinner.Start()
@@ -178,22 +278,373 @@ func installFromPackageJSON() {
 		os.Exit(1)
 	}
 	instSpinner.Stop()
+	if err := linkWorkspaceDependencies(members, res); err != nil {
+		ui.ErrorMessage(fmt.Errorf("failed to link workspace packages: %w", err))
+		os.Exit(1)
+	}
 	ui.InstallStep("✅", "All packages installed")
 
+	if !fromLock {
+		_ = lockfile.Save(".", buildLockfile(directSpecs, members, res, order, inst))
+	}
+
+	duration := time.Since(startTime)
+	packageNames := make([]string, len(installed))
+	for i, dep := range installed {
+		packageNames[i] = dep.Name
+	}
+	ui.InstallSummary(packageNames, duration.String())
+}
+
+// newProjectResolver builds a resolver configured from the install flags
+// and the project's package.json
+func newProjectResolver(pkg *packagejson.PackageJSON, onProgress func(string)) (*resolver.Resolver, error) {
+	if resolveConcurrency == 0 {
+		resolveConcurrency = autoConcurrency()
+	}
+	res := resolver.NewResolverWithOptions(devFlag, resolveConcurrency, onProgress)
+	res.SetPeerOptions(autoInstallPeers, strictPeerDeps)
+	res.SetRootName(pkg.Name)
+	overrides, err := resolver.OverridesFromPackageJSON(pkg)
+	if err != nil {
+		return nil, err
+	}
+	res.SetOverrides(overrides)
+	return res, nil
+}
+
+// projectRootSpecs returns the direct dependencies to resolve and lock.
+// devDependencies are always included so that the lockfile does not depend
+// on --dev; installSpecs decides what gets installed.
+func projectRootSpecs(pkg *packagejson.PackageJSON) map[string]string {
+	return pkg.GetDependencies()
+}
+
+// installSpecs narrows the locked direct specs to the ones installed:
+// devDependencies only with --dev
+func installSpecs(pkg *packagejson.PackageJSON, directSpecs map[string]string) map[string]string {
+	if devFlag {
+		return directSpecs
+	}
+	specs := make(map[string]string, len(pkg.Dependencies))
+	for name := range pkg.Dependencies {
+		// a name in both sections is locked with its devDependencies spec
+		specs[name] = directSpecs[name]
+	}
+	return specs
+}
+
+// installedOrder keeps the packages of order that the layout places, so that
+// dev-only packages left out without --dev are not checked or reported
+func installedOrder(order []*resolver.Dependency, pkgs []installer.PackageSpec) []*resolver.Dependency {
+	placed := make(map[string]bool, len(pkgs))
+	for _, p := range pkgs {
+		placed[p.Name+"@"+p.Version] = true
+	}
+	var out []*resolver.Dependency
+	for _, d := range order {
+		if placed[d.Name+"@"+d.Resolved] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// linkWorkspaceDependencies symlinks members into the node_modules of the
+// members that depend on them
+func linkWorkspaceDependencies(members []*workspace.Member, res *resolver.Resolver) error {
+	for _, m := range members {
+		inst := installer.NewInstaller(filepath.Join(m.Dir, "node_modules"))
+		for name, spec := range m.Manifest.GetDependencies() {
+			d := res.Lookup(name, spec)
+			if d == nil || !d.Linked || !strings.HasPrefix(d.Source, "workspace:") {
+				continue
+			}
+			if err := inst.LinkWorkspacePackage(name, d.LocalPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadLockedGraph rebuilds the graph from .npgo-lock.yaml when it still
+// satisfies package.json; otherwise the caller resolves from the registry.
+// A lockfile that cannot be read, or locked content that no longer matches
+// its storeHash, is an error.
+func loadLockedGraph(res *resolver.Resolver, rootSpecs map[string]string) (map[string]*resolver.Dependency, bool, error) {
+	lf, err := lockfile.Load(".")
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		// re-resolving would overwrite a newer or damaged lockfile
+		return nil, false, err
+	}
+	graph, err := res.LoadLockfile(lf, rootSpecs, ".")
+	if errors.Is(err, cas.ErrIntegrity) {
+		// re-resolving would silently accept the changed content
+		return nil, false, err
+	}
+	if err != nil {
+		if devFlag || !errors.Is(err, resolver.ErrLockfileStale) {
+			ui.InstallStep("🔄", fmt.Sprintf("Re-resolving: %v", err))
+		}
+		return nil, false, nil
+	}
+	return graph, true, nil
+}
+
+// loadFrozenGraph loads the locked graph for --frozen-lockfile and npgo ci;
+// a missing lockfile or any drift from package.json is an error
+func loadFrozenGraph(res *resolver.Resolver, directSpecs map[string]string) (map[string]*resolver.Dependency, error) {
+	lf, err := lockfile.Load(".")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("frozen lockfile: %s not found (run 'npgo install' to create it)", lockfile.Path("."))
+		}
+		return nil, err
+	}
+	if drift := res.LockfileDrift(lf, directSpecs); len(drift) > 0 {
+		lines := make([]string, len(drift))
+		for i, d := range drift {
+			lines[i] = d.String()
+		}
+		return nil, fmt.Errorf("%s is out of date with package.json (run 'npgo install' to update it):\n  %s",
+			lockfile.Path("."), strings.Join(lines, "\n  "))
+	}
+	return res.LoadLockfile(lf, directSpecs, ".")
+}
+
+// buildLockfile records the resolved graph: the importers (root and
+// workspace members) with their direct edges, and every package with its
+// edges and metadata. inst supplies CAS hashes of downloaded tarballs and
+// may be nil when nothing was installed.
+func buildLockfile(directSpecs map[string]string, members []*workspace.Member, res *resolver.Resolver, order []*resolver.Dependency, inst *installer.Installer) *lockfile.LockFile {
+	root := make(map[string]lockfile.Edge, len(directSpecs))
+	for name, spec := range directSpecs {
+		if d := res.Lookup(name, spec); d != nil {
+			root[name] = lockfile.Edge{Spec: spec, Version: d.Resolved}
+		}
+	}
+	importers := map[string]lockfile.Importer{".": {Dependencies: root}}
+	for _, m := range members {
+		for _, d := range order {
+			if d.Source != "workspace:"+m.RelDir {
+				continue
+			}
+			// declared specs, even where an override rewrote the edge
+			edges := make(map[string]lockfile.Edge)
+			for name, spec := range resolver.MemberSpecs(m) {
+				if c := d.Dependencies[name]; c != nil {
+					edges[name] = lockfile.Edge{Spec: spec, Version: c.Resolved, Scope: c.Scope}
+				}
+			}
+			importers[m.RelDir] = lockfile.Importer{Dependencies: edges}
+		}
+	}
+	return &lockfile.LockFile{OverridesHash: res.OverridesHash(), Importers: importers, Packages: lockEntries(order, inst)}
+}
+
+func lockEntries(order []*resolver.Dependency, inst *installer.Installer) []lockfile.PackageEntry {
 	var lockPkgs []lockfile.PackageEntry
 	for _, d := range order {
+		var edges map[string]lockfile.Edge
+		for cn, cs := range d.RawDeps {
+			c := d.Dependencies[cn]
+			if c == nil {
+				continue
+			}
+			if edges == nil {
+				edges = make(map[string]lockfile.Edge)
+			}
+			edge := lockfile.Edge{Spec: cs, Version: c.Resolved, Scope: c.Scope, Optional: d.OptionalDeps[cn]}
+			// record the declared spec; an override is kept beside it
+			if declared := d.DeclaredSpec(cn); declared != "" && declared != cs {
+				edge.Spec, edge.Override = declared, cs
+			}
+			edges[cn] = edge
+		}
+		storeHash := d.StoreHash
+		if storeHash == "" && inst != nil {
+			storeHash = inst.StoreHash(d.Name, d.Resolved)
+		}
 		lockPkgs = append(lockPkgs, lockfile.PackageEntry{
-			Name: d.Name, Version: d.Resolved, Resolved: d.TarballURL, Integrity: "sha256", // TODO compute
+			Name:             d.Name,
+			Version:          d.Resolved,
+			Resolved:         d.TarballURL,
+			Integrity:        d.Integrity,
+			StoreHash:        storeHash,
+			Override:         d.Override,
+			Scope:            d.Scope,
+			Source:           d.Source,
+			Dependencies:     edges,
+			OS:               d.OS,
+			CPU:              d.CPU,
+			Libc:             d.Libc,
+			Engines:          d.Engines,
+			Bin:              d.Bin,
+			HasInstallScript: d.HasInstallScript,
+			Deprecated:       d.Deprecated,
 		})
 	}
-	_ = lockfile.Save(".", &lockfile.LockFile{LockfileVersion: 1, Packages: lockPkgs})
+	return lockPkgs
+}
 
-	duration := time.Since(startTime)
-	packageNames := make([]string, len(order))
-	for i, dep := range order {
-		packageNames[i] = dep.Name
+// reportDeprecations prints every deprecated package with the path that pulls
+// it in; under --strict-deprecations a deprecated direct dependency is an error
+func reportDeprecations(deps []resolver.Deprecation) error {
+	if len(deps) == 0 {
+		return nil
 	}
-	ui.InstallSummary(packageNames, duration.String())
+	ui.Warning.Printf("⚠️  Deprecated packages (%d):\n", len(deps))
+	var direct []string
+	for _, d := range deps {
+		ui.Muted.Printf("   - %s\n", d)
+		if d.Direct {
+			direct = append(direct, d.Dep.Name+"@"+d.Dep.Resolved)
+		}
+	}
+	if strictDeprecations && len(direct) > 0 {
+		return fmt.Errorf("deprecated direct dependencies (--strict-deprecations): %s", strings.Join(direct, ", "))
+	}
+	return nil
+}
+
+// checkEngines enforces the root engines.npgo against this binary and checks
+// engines.node of the project and every package against the local node.
+// Node mismatches are warnings unless --engine-strict (or engine-strict=true).
+func checkEngines(pkg *packagejson.PackageJSON, order []*resolver.Dependency) error {
+	if want := pkg.EngineRanges()["npgo"]; want != "" && !platform.EngineSatisfied(want, currentVersion) {
+		return fmt.Errorf("%s requires npgo %s, but this is npgo %s", projectLabel(pkg), want, currentVersion)
+	}
+	node := platform.NodeVersion()
+	if node == "" {
+		if devFlag {
+			ui.Muted.Println("   node not found on PATH: engines.node not checked")
+		}
+		return nil
+	}
+	var issues []string
+	if want := pkg.EngineRanges()["node"]; want != "" && !platform.EngineSatisfied(want, node) {
+		issues = append(issues, fmt.Sprintf("%s requires node %s", projectLabel(pkg), want))
+	}
+	for _, d := range order {
+		if want := d.Engines["node"]; want != "" && !platform.EngineSatisfied(want, node) {
+			issues = append(issues, fmt.Sprintf("%s@%s requires node %s", d.Name, d.Resolved, want))
+		}
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	strict := engineStrict || (projectConfig != nil && projectConfig.Bool("engine-strict"))
+	if strict {
+		return fmt.Errorf("unsupported engine (node %s, --engine-strict):\n  %s", node, strings.Join(issues, "\n  "))
+	}
+	for _, issue := range issues {
+		ui.Warning.Printf("⚠️  %s (current: node %s)\n", issue, node)
+	}
+	return nil
+}
+
+func projectLabel(pkg *packagejson.PackageJSON) string {
+	if pkg.Name != "" {
+		return pkg.Name
+	}
+	return "the project"
+}
+
+// applyRegistryConfig reads .npmrc and applies the registries, the network
+// mode and the publish cutoff; command-line flags take precedence over the config
+func applyRegistryConfig() {
+	cfg := loadRegistries()
+	applyNetworkMode(cfg)
+	if err := applyReleaseCutoff(cfg); err != nil {
+		ui.ErrorMessage(err)
+		os.Exit(1)
+	}
+}
+
+// loadRegistries reads .npmrc and points the default registry at the
+// configured registry=, @scope:registry= and per-host credentials
+func loadRegistries() *config.Config {
+	cfg, err := config.Load(".")
+	if err != nil {
+		ui.ErrorMessage(fmt.Errorf("failed to read .npmrc: %w", err))
+		os.Exit(1)
+	}
+	projectConfig = cfg
+	registry.Default = registry.NewRegistryFromConfig(cfg.All())
+	return cfg
+}
+
+// applyNetworkMode sets the registry mode from --offline/--prefer-offline,
+// falling back to the offline and prefer-offline settings
+func applyNetworkMode(cfg *config.Config) {
+	mode := registry.Online
+	switch {
+	case offlineFlag:
+		mode = registry.Offline
+	case preferOfflineFlag:
+		mode = registry.PreferOffline
+	case cfg.Bool("offline"):
+		mode = registry.Offline
+	case cfg.Bool("prefer-offline"):
+		mode = registry.PreferOffline
+	}
+	registry.SetNetworkMode(mode)
+	switch mode {
+	case registry.Offline:
+		ui.InstallStep("✈️", "Offline: using only the registry cache and the store")
+	case registry.PreferOffline:
+		ui.InstallStep("✈️", "Prefer offline: fresh cached metadata is not revalidated")
+	}
+}
+
+// applyReleaseCutoff combines --before (or the before setting) with
+// minimum-release-age, in minutes as in pnpm; the earlier cutoff wins
+func applyReleaseCutoff(cfg *config.Config) error {
+	var cutoff time.Time
+	before := beforeFlag
+	if before == "" {
+		before = cfg.Get("before")
+	}
+	if before != "" {
+		t, err := parseTimestamp(before)
+		if err != nil {
+			return fmt.Errorf("invalid --before %q: %w", before, err)
+		}
+		cutoff = t
+	}
+	age := cfg.Get("minimum-release-age")
+	if age == "" {
+		age = cfg.Get("minimumreleaseage")
+	}
+	if age != "" {
+		minutes, err := strconv.Atoi(age)
+		if err != nil || minutes < 0 {
+			return fmt.Errorf("invalid minimum-release-age %q: want a number of minutes", age)
+		}
+		t := time.Now().Add(-time.Duration(minutes) * time.Minute)
+		if minutes > 0 && (cutoff.IsZero() || t.Before(cutoff)) {
+			cutoff = t
+		}
+	}
+	registry.SetPublishedBefore(cutoff)
+	if !cutoff.IsZero() {
+		ui.InstallStep("⏳", fmt.Sprintf("Ignoring versions published after %s", cutoff.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// parseTimestamp accepts an RFC 3339 timestamp or a plain date (UTC midnight)
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or an RFC 3339 timestamp")
 }
 
 func autoConcurrency() int {
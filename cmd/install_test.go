@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"npgo/internal/lockfile"
+	"npgo/internal/packagejson"
+	"npgo/internal/registry"
+	"npgo/internal/resolver"
+)
+
+// sortedLockfile orders the package entries, which follow TopoOrder
+func sortedLockfile(lf *lockfile.LockFile) *lockfile.LockFile {
+	sort.Slice(lf.Packages, func(i, j int) bool {
+		a, b := lf.Packages[i], lf.Packages[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Scope < b.Scope
+	})
+	return lf
+}
+
+func TestLockfileRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mem := registry.NewMemory()
+	for _, md := range []registry.PackageMetadata{
+		{Name: "a", Version: "1.0.0", Dependencies: map[string]string{"b": "^1", "c": "^1"}},
+		{Name: "b", Version: "1.0.0", Dependencies: map[string]string{"c": "^1"}},
+		{Name: "c", Version: "1.0.0"},
+		{Name: "c", Version: "1.1.0"},
+		{Name: "c", Version: "2.0.0"},
+	} {
+		if err := mem.Add(md, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var pkg packagejson.PackageJSON
+	if err := json.Unmarshal([]byte(`{"dependencies":{"a":"^1","b":"^1"},"overrides":{"a":{"c":"1.0.0"}}}`), &pkg); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := resolver.OverridesFromPackageJSON(&pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newResolver := func() *resolver.Resolver {
+		r := resolver.NewResolver()
+		r.SetMetadataSource(mem)
+		r.SetOverrides(rules)
+		return r
+	}
+	root := pkg.Dependencies
+
+	res := newResolver()
+	graph, err := res.BuildGraph(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := resolver.TopoOrder(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := buildLockfile(root, nil, res, order, nil)
+	dir := t.TempDir()
+	if err := lockfile.Save(dir, written); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := lockfile.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sortedLockfile(loaded), sortedLockfile(written)) {
+		t.Fatalf("Load = %+v\nwant %+v", loaded, written)
+	}
+
+	// the overridden edge keeps its declared spec beside the override
+	var overridden *lockfile.Edge
+	for _, e := range loaded.Packages {
+		if e.Name == "a" {
+			edge := e.Dependencies["c"]
+			overridden = &edge
+		}
+	}
+	if overridden == nil || overridden.Spec != "^1" || overridden.Override != "1.0.0" || overridden.Version != "1.0.0" {
+		t.Errorf("a's edge to c = %+v, want spec ^1 overridden to 1.0.0", overridden)
+	}
+
+	locked := newResolver()
+	lgraph, err := locked.LoadLockfile(loaded, root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lorder, err := resolver.TopoOrder(lgraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := buildLockfile(root, nil, locked, lorder, nil)
+	rebuilt.LockfileVersion = loaded.LockfileVersion
+	if !reflect.DeepEqual(sortedLockfile(rebuilt), sortedLockfile(loaded)) {
+		t.Errorf("lockfile from the locked graph = %+v\nwant %+v", rebuilt, loaded)
+	}
+	for name, spec := range root {
+		want, got := res.Lookup(name, spec), locked.Lookup(name, spec)
+		if want == nil || got == nil || got.Resolved != want.Resolved {
+			t.Errorf("Lookup(%s, %s) = %+v, want %+v", name, spec, got, want)
+		}
+	}
+}
@@ -29,7 +29,7 @@ Features:
 		// Shorthand: npgo <script> == npgo run <script>
 		if len(args) > 0 {
 			known := map[string]struct{}{
-				"fetch": {}, "install": {}, "i": {}, "run": {}, "update": {}, "help": {}, "--help": {}, "-h": {},
+				"fetch": {}, "install": {}, "i": {}, "run": {}, "update": {}, "dist-tag": {}, "dedupe": {}, "ci": {}, "help": {}, "--help": {}, "-h": {},
 			}
 			if _, ok := known[args[0]]; !ok {
 				// treat as script name
@@ -41,7 +41,10 @@ Features:
 		fmt.Println("Available commands:")
 		fmt.Println("  npgo fetch <package>@<version>  - Fetch a package")
 		fmt.Println("  npgo install <package>         - Install a package")
+		fmt.Println("  npgo ci                        - Clean install exactly what the lockfile records")
 		fmt.Println("  npgo run <script>              - Run a package.json script (or 'npgo <script>')")
+		fmt.Println("  npgo dist-tag ls <package>     - List a package's dist-tags")
+		fmt.Println("  npgo dedupe [--check]          - Deduplicate versions in the lockfile")
 		fmt.Println("  npgo update                    - Update npgo to latest version")
 		fmt.Println("  npgo --help                     - Show help")
 		fmt.Println()
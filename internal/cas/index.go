@@ -0,0 +1,89 @@
+package cas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The store index maps a registry package (name@version plus its published
+// integrity) to the CAS hash of its tarball, so a package already in the
+// store can be linked without touching the network. Each entry is a small
+// file written atomically, which keeps concurrent installs safe.
+
+func indexDir() (string, error) {
+	root, err := baseStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "index"), nil
+}
+
+func indexEntryPath(name, version, integrity string) (string, error) {
+	dir, err := indexDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(name + "@" + version + "\x00" + integrity))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(dir, key[:2], key), nil
+}
+
+// LookupIndex returns the CAS hash and package path recorded for a package,
+// if the store still holds it. Packages without integrity are never indexed.
+func LookupIndex(name, version, integrity string) (string, string, bool) {
+	if integrity == "" {
+		return "", "", false
+	}
+	p, err := indexEntryPath(name, version, integrity)
+	if err != nil {
+		return "", "", false
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", "", false
+	}
+	hash := strings.TrimSpace(string(data))
+	if ok, _ := Exists(hash); !ok {
+		return "", "", false
+	}
+	pkg, err := PackagePath(hash)
+	if err != nil {
+		return "", "", false
+	}
+	return hash, pkg, true
+}
+
+// RecordIndex remembers the CAS hash a verified package was stored under
+func RecordIndex(name, version, integrity, hash string) error {
+	if integrity == "" || hash == "" {
+		return nil
+	}
+	p, err := indexEntryPath(name, version, integrity)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(hash + "\n"); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package cas
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// ErrIntegrity means downloaded bytes do not match the published integrity
+var ErrIntegrity = errors.New("integrity check failed")
+
+// strongest first, as npm's ssri picks them
+var sriAlgorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha512", sha512.New},
+	{"sha384", sha512.New384},
+	{"sha256", sha256.New},
+	{"sha1", sha1.New},
+}
+
+// Verifier hashes a stream and checks it against an SRI string such as
+// "sha512-<base64>" (several space-separated entries are allowed)
+type Verifier struct {
+	algo string
+	want []byte
+	h    hash.Hash
+}
+
+// NewVerifier returns nil for an empty SRI, and an error when none of its
+// entries uses a supported algorithm
+func NewVerifier(sri string) (*Verifier, error) {
+	if strings.TrimSpace(sri) == "" {
+		return nil, nil
+	}
+	entries := strings.Fields(sri)
+	for _, alg := range sriAlgorithms {
+		for _, e := range entries {
+			prefix := alg.name + "-"
+			if !strings.HasPrefix(e, prefix) {
+				continue
+			}
+			digest := e[len(prefix):]
+			// options such as "?foo" are allowed after the digest
+			if i := strings.IndexByte(digest, '?'); i >= 0 {
+				digest = digest[:i]
+			}
+			want, err := base64.StdEncoding.DecodeString(digest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integrity %q: %w", e, err)
+			}
+			return &Verifier{algo: alg.name, want: want, h: alg.new()}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported integrity %q", sri)
+}
+
+func (v *Verifier) Write(p []byte) (int, error) {
+	return v.h.Write(p)
+}
+
+// Verify compares everything written so far with the expected digest
+func (v *Verifier) Verify() error {
+	got := v.h.Sum(nil)
+	if !bytes.Equal(got, v.want) {
+		return fmt.Errorf("%w: expected %s-%s, got %s-%s", ErrIntegrity,
+			v.algo, base64.StdEncoding.EncodeToString(v.want), v.algo, base64.StdEncoding.EncodeToString(got))
+	}
+	return nil
+}
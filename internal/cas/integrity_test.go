@@ -0,0 +1,159 @@
+package cas
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"npgo/internal/registry"
+)
+
+func sri(algo string, sum []byte) string {
+	return algo + "-" + base64.StdEncoding.EncodeToString(sum)
+}
+
+func sha1Sum(data []byte) []byte   { s := sha1.Sum(data); return s[:] }
+func sha256Sum(data []byte) []byte { s := sha256.Sum256(data); return s[:] }
+func sha512Sum(data []byte) []byte { s := sha512.Sum512(data); return s[:] }
+
+func TestVerifier(t *testing.T) {
+	data := []byte("tarball bytes")
+	other := []byte("something else")
+	tests := []struct {
+		name      string
+		sri       string
+		wantNil   bool
+		newErr    bool
+		verifyErr bool
+	}{
+		{name: "empty", sri: " ", wantNil: true},
+		{name: "sha512", sri: sri("sha512", sha512Sum(data))},
+		{name: "sha256", sri: sri("sha256", sha256Sum(data))},
+		{name: "sha1", sri: sri("sha1", sha1Sum(data))},
+		{name: "options after the digest", sri: sri("sha512", sha512Sum(data)) + "?foo"},
+		{name: "sha512 mismatch", sri: sri("sha512", sha512Sum(other)), verifyErr: true},
+		{name: "sha1 mismatch", sri: sri("sha1", sha1Sum(other)), verifyErr: true},
+		{name: "multi-hash picks sha512", sri: sri("sha1", sha1Sum(other)) + " " + sri("sha512", sha512Sum(data))},
+		{name: "multi-hash checks the strongest", sri: sri("sha1", sha1Sum(data)) + " " + sri("sha512", sha512Sum(other)), verifyErr: true},
+		{name: "unknown algorithms are skipped", sri: "md5-AAAA " + sri("sha256", sha256Sum(data))},
+		{name: "unsupported", sri: "md5-AAAA", newErr: true},
+		{name: "bad base64", sri: "sha512-!!!", newErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier(tt.sri)
+			if (err != nil) != tt.newErr {
+				t.Fatalf("NewVerifier(%q) error = %v, want error %v", tt.sri, err, tt.newErr)
+			}
+			if err != nil {
+				return
+			}
+			if (v == nil) != tt.wantNil {
+				t.Fatalf("NewVerifier(%q) = %v, want nil %v", tt.sri, v, tt.wantNil)
+			}
+			if v == nil {
+				return
+			}
+			v.Write(data)
+			err = v.Verify()
+			if (err != nil) != tt.verifyErr {
+				t.Fatalf("Verify() = %v, want error %v", err, tt.verifyErr)
+			}
+			if err != nil && !errors.Is(err, ErrIntegrity) {
+				t.Errorf("Verify() = %v, want ErrIntegrity", err)
+			}
+		})
+	}
+}
+
+// packTarball builds an npm-style tarball holding only package/package.json
+func packTarball(t *testing.T, manifest string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0644, Size: int64(len(manifest))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportTarball(t *testing.T) {
+	tarball := packTarball(t, `{"name":"a","version":"1.0.0"}`)
+	wrong := packTarball(t, `{"name":"a","version":"1.0.1"}`)
+	tests := []struct {
+		name string
+		dist registry.PackageMetadata
+		want error
+	}{
+		{name: "no integrity"},
+		{name: "sha512 integrity", dist: withDist(sri("sha512", sha512Sum(tarball)), "")},
+		{name: "multi-hash integrity", dist: withDist(sri("sha1", sha1Sum(tarball))+" "+sri("sha512", sha512Sum(tarball)), "")},
+		{name: "shasum falls back to sha1", dist: withDist("", hex.EncodeToString(sha1Sum(tarball)))},
+		{name: "integrity wins over shasum", dist: withDist(sri("sha512", sha512Sum(tarball)), hex.EncodeToString(sha1Sum(wrong)))},
+		{name: "integrity mismatch", dist: withDist(sri("sha512", sha512Sum(wrong)), ""), want: ErrIntegrity},
+		{name: "shasum mismatch", dist: withDist("", hex.EncodeToString(sha1Sum(wrong))), want: ErrIntegrity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			integrity := tt.dist.SRI()
+			hash, pkg, err := ImportTarball(bytes.NewReader(tarball), integrity)
+			if err == nil {
+				// as the installer does: only verified imports are indexed
+				if err := RecordIndex("a", "1.0.0", integrity, hash); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ImportTarball(%q) = %v, want %v", integrity, err, tt.want)
+			}
+			if tt.want == nil {
+				if hash != HashBytes(tarball) {
+					t.Errorf("hash = %s, want %s", hash, HashBytes(tarball))
+				}
+				if _, err := os.Stat(filepath.Join(pkg, "package.json")); err != nil {
+					t.Errorf("package.json not in store: %v", err)
+				}
+				return
+			}
+			// the partial extraction must not survive in the store
+			root, _ := baseStoreDir()
+			entries, _ := os.ReadDir(root)
+			for _, e := range entries {
+				t.Errorf("store holds %s after a failed import", e.Name())
+			}
+			if ok, _ := Exists(HashBytes(tarball)); ok {
+				t.Error("Exists reports the rejected tarball")
+			}
+			if _, _, ok := LookupIndex("a", "1.0.0", integrity); ok {
+				t.Error("LookupIndex finds the rejected tarball")
+			}
+		})
+	}
+}
+
+func withDist(integrity, shasum string) registry.PackageMetadata {
+	var md registry.PackageMetadata
+	md.Dist.Integrity = integrity
+	md.Dist.Shasum = shasum
+	return md
+}
@@ -7,6 +7,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+
+	"npgo/internal/extractor"
 )
 
 func baseStoreDir() (string, error) {
@@ -54,12 +57,14 @@ func ExtractedCachePath(hash string) (string, error) {
 	return filepath.Join(root, hash), nil
 }
 
+// Exists reports whether the store holds content for hash. Empty package
+// dirs (left by older versions that created them before importing) do not count.
 func Exists(hash string) (bool, error) {
 	p, err := PackagePath(hash)
 	if err != nil {
 		return false, err
 	}
-	if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+	if entries, err := os.ReadDir(p); err == nil && len(entries) > 0 {
 		return true, nil
 	}
 	return false, nil
@@ -100,3 +105,159 @@ func EnsureDirs(hash string) (string, error) {
 	}
 	return p, nil
 }
+
+// ImportTarball extracts a package tarball into the store, keyed by the
+// sha256 of the tarball bytes. It returns the hash and the package path.
+// A non-empty integrity (SRI) is verified over the whole stream before
+// anything enters the store; on a mismatch the extraction is discarded.
+func ImportTarball(r io.Reader, integrity string) (string, string, error) {
+	verifier, err := NewVerifier(integrity)
+	if err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	var w io.Writer = h
+	if verifier != nil {
+		w = io.MultiWriter(h, verifier)
+	}
+	tmpDir, err := storeTempDir()
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPkg := filepath.Join(tmpDir, "package")
+	tee := io.TeeReader(r, w)
+	if err := extractor.ExtractFromReader(tee, tmpPkg); err != nil {
+		return "", "", err
+	}
+	// hash the whole stream even if the tar reader stopped early
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return "", "", err
+	}
+	if verifier != nil {
+		if err := verifier.Verify(); err != nil {
+			return "", "", err
+		}
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	p, err := moveIntoStore(tmpPkg, hash)
+	return hash, p, err
+}
+
+// ImportDir copies a package directory into the store. The key hashes the
+// sorted relative paths and contents, skipping node_modules and .git.
+func ImportDir(src string) (string, string, error) {
+	var files []string
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && p != src && (info.Name() == "node_modules" || info.Name() == ".git") {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			rel, _ := filepath.Rel(src, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, rel := range files {
+		f, err := os.Open(filepath.Join(src, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", "", err
+		}
+		_, _ = io.WriteString(h, rel+"\x00")
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", "", err
+		}
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if ok, _ := Exists(hash); ok {
+		p, err := PackagePath(hash)
+		return hash, p, err
+	}
+
+	tmpDir, err := storeTempDir()
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPkg := filepath.Join(tmpDir, "package")
+	for _, rel := range files {
+		if err := copyRegular(filepath.Join(src, filepath.FromSlash(rel)), filepath.Join(tmpPkg, filepath.FromSlash(rel))); err != nil {
+			return "", "", err
+		}
+	}
+	if err := os.MkdirAll(tmpPkg, 0755); err != nil {
+		return "", "", err
+	}
+	p, err := moveIntoStore(tmpPkg, hash)
+	return hash, p, err
+}
+
+// storeTempDir stages work inside the store so the final rename never
+// crosses filesystems
+func storeTempDir() (string, error) {
+	root, err := baseStoreDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(root, ".tmp-")
+}
+
+// moveIntoStore renames an extracted package into <store>/<hash>/package
+// unless the store already holds that hash
+func moveIntoStore(tmpPkg, hash string) (string, error) {
+	p, err := PackagePath(hash)
+	if err != nil {
+		return "", err
+	}
+	if ok, _ := Exists(hash); ok {
+		return p, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", fmt.Errorf("failed to create CAS dir: %w", err)
+	}
+	_ = os.Remove(p) // an empty placeholder dir would block the rename
+	if err := os.Rename(tmpPkg, p); err != nil {
+		// another process may have won the race
+		if ok, _ := Exists(hash); ok {
+			return p, nil
+		}
+		return "", fmt.Errorf("failed to move package into CAS: %w", err)
+	}
+	return p, nil
+}
+
+func copyRegular(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
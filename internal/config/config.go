@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Config holds npm-style settings merged from ~/.npmrc, the project .npmrc
+// and npm_config_* environment variables (later sources win). Keys are
+// case-insensitive; ${VAR} in .npmrc is replaced from the environment.
+type Config struct {
+	values map[string]string
+}
+
+// Load reads the user and project .npmrc files. Missing files are ignored.
+func Load(projectDir string) (*Config, error) {
+	c := &Config{values: make(map[string]string)}
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := c.readFile(filepath.Join(home, ".npmrc")); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.readFile(filepath.Join(projectDir, ".npmrc")); err != nil {
+		return nil, err
+	}
+	for _, kv := range os.Environ() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(strings.ToLower(k), "npm_config_") {
+			continue
+		}
+		key := strings.ReplaceAll(strings.ToLower(k[len("npm_config_"):]), "_", "-")
+		c.values[key] = v
+	}
+	return c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			// a bare key is a boolean flag, as in npm
+			k, v = line, "true"
+		}
+		k, err := expandEnv(strings.TrimSpace(k))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v, err = expandEnv(strings.Trim(strings.TrimSpace(v), `"'`))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		c.values[strings.ToLower(k)] = v
+	}
+	return sc.Err()
+}
+
+var envRef = regexp.MustCompile(`\$\{([^${}?]+)(\?)?\}`)
+
+// expandEnv replaces ${VAR}; an unset variable is an error unless written
+// ${VAR?}, which expands to "" (npm's rules)
+func expandEnv(s string) (string, error) {
+	var missing string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envRef.FindStringSubmatch(ref)
+		v, ok := os.LookupEnv(m[1])
+		if !ok && m[2] == "" && missing == "" {
+			missing = m[1]
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("environment variable %s is not set (use ${%s?} to allow that)", missing, missing)
+	}
+	return out, nil
+}
+
+// Get returns the raw value of key, or "" when unset
+func (c *Config) Get(key string) string {
+	return c.values[strings.ToLower(key)]
+}
+
+// All returns a copy of every setting, keyed in lower case
+func (c *Config) All() map[string]string {
+	out := make(map[string]string, len(c.values))
+	for k, v := range c.values {
+		out[k] = v
+	}
+	return out
+}
+
+// Bool reports whether key is set to a truthy value
+func (c *Config) Bool(key string) bool {
+	switch strings.ToLower(c.Get(key)) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeNpmrc(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, ".npmrc"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("NPGO_TEST_TOKEN", "s3cret")
+	t.Setenv("NPM_CONFIG_FETCH_RETRIES", "5")
+	t.Setenv("npm_config_strict_ssl", "false")
+	writeNpmrc(t, home, strings.Join([]string{
+		"registry=https://home.example/",
+		"fetch-retries=1",
+		"cache-min=10",
+		"@ourco:registry=https://npm.ourco.dev/",
+		"//npm.ourco.dev/:_authToken=${NPGO_TEST_TOKEN}",
+	}, "\n"))
+	writeNpmrc(t, project, strings.Join([]string{
+		"# comment",
+		"; another comment",
+		"",
+		"Registry = \"https://project.example/\"",
+		"fetch-retries=2",
+		"strict-ssl=true",
+		"always-auth",
+		"optional=${NPGO_TEST_UNSET?}",
+	}, "\n"))
+
+	c, err := Load(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key, want string
+	}{
+		{"registry", "https://project.example/"}, // project over home, quotes trimmed
+		{"REGISTRY", "https://project.example/"}, // keys are case-insensitive
+		{"cache-min", "10"},                      // home only
+		{"fetch-retries", "5"},                   // env over both files
+		{"strict-ssl", "false"},                  // lower-case env names too
+		{"always-auth", "true"},                  // bare key
+		{"optional", ""},                         // ${VAR?} may be unset
+		{"@ourco:registry", "https://npm.ourco.dev/"},
+		{"//npm.ourco.dev/:_authtoken", "s3cret"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := c.Get(tt.key); got != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+	if !c.Bool("always-auth") || c.Bool("strict-ssl") || c.Bool("missing") {
+		t.Errorf("Bool: always-auth=%v strict-ssl=%v missing=%v", c.Bool("always-auth"), c.Bool("strict-ssl"), c.Bool("missing"))
+	}
+	if all := c.All(); all["//npm.ourco.dev/:_authtoken"] != "s3cret" {
+		t.Errorf("All() = %v", all)
+	}
+}
+
+func TestLoadUnsetVariable(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	writeNpmrc(t, project, "//npm.ourco.dev/:_authToken=${NPGO_TEST_UNSET}\n")
+	if _, err := Load(project); err == nil || !strings.Contains(err.Error(), "NPGO_TEST_UNSET") {
+		t.Errorf("Load = %v, want an error naming NPGO_TEST_UNSET", err)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("NPGO_TEST_A", "a")
+	t.Setenv("NPGO_TEST_EMPTY", "")
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${NPGO_TEST_A}", want: "a"},
+		{in: "x-${NPGO_TEST_A}-${NPGO_TEST_A}-y", want: "x-a-a-y"},
+		{in: "${NPGO_TEST_EMPTY}", want: ""},
+		{in: "${NPGO_TEST_UNSET?}", want: ""},
+		{in: "${NPGO_TEST_UNSET}", wantErr: true},
+		{in: "$NPGO_TEST_A", want: "$NPGO_TEST_A"},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, %v; want %q (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
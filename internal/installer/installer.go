@@ -1,8 +1,6 @@
 package installer
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,12 +9,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
 	"npgo/internal/cache"
 	"npgo/internal/cas"
-	"npgo/internal/extractor"
+	"npgo/internal/platform"
 	"npgo/internal/registry"
 	"npgo/internal/ui"
 )
@@ -24,6 +23,11 @@ import (
 type Installer struct {
 	nodeModulesPath string
 	debug           bool
+	// storeHashes maps name@version to the CAS hash of its downloaded tarball
+	storeHashes sync.Map
+	// metadata and tarballs default to registry.Default
+	metadata registry.MetadataSource
+	tarballs registry.TarballSource
 }
 
 // PackageSpec is a minimal spec for pipeline install
@@ -31,14 +35,62 @@ type PackageSpec struct {
 	Name       string
 	Version    string
 	TarballURL string
+	// Integrity is the registry SRI the downloaded tarball must match
+	Integrity string
+	// Path is the install location relative to node_modules, e.g.
+	// "a/node_modules/debug" for a nested copy; empty means top-level.
+	Path string
+	// Optional packages that fail to download or extract are skipped with a warning
+	Optional bool
+	// LocalPath is set for non-registry packages whose content is already on
+	// disk (CAS import or link: directory); nothing is downloaded for them
+	LocalPath string
+	// Linked packages (link: and workspace members) are always symlinked,
+	// even when they host nested dependencies
+	Linked bool
+}
+
+func (p PackageSpec) installPath() string {
+	if p.Path == "" {
+		return p.Name
+	}
+	return p.Path
+}
+
+// key identifies the content to fetch: registry packages by name@version,
+// local ones by their directory
+func (p PackageSpec) key() string {
+	if p.LocalPath != "" {
+		return p.Name + "@" + p.LocalPath
+	}
+	return p.Name + "@" + p.Version
 }
 
 func NewInstaller(nodeModulesPath string) *Installer {
-	return &Installer{nodeModulesPath: nodeModulesPath, debug: false}
+	return &Installer{nodeModulesPath: nodeModulesPath, debug: false, metadata: registry.Default, tarballs: registry.Default}
 }
 
 func NewInstallerWithDebug(nodeModulesPath string, debug bool) *Installer {
-	return &Installer{nodeModulesPath: nodeModulesPath, debug: debug}
+	return &Installer{nodeModulesPath: nodeModulesPath, debug: debug, metadata: registry.Default, tarballs: registry.Default}
+}
+
+// SetSources replaces where InstallPackage reads metadata and where tarballs
+// are downloaded from; a nil source keeps the current one
+func (i *Installer) SetSources(metadata registry.MetadataSource, tarballs registry.TarballSource) {
+	if metadata != nil {
+		i.metadata = metadata
+	}
+	if tarballs != nil {
+		i.tarballs = tarballs
+	}
+}
+
+// StoreHash returns the CAS hash recorded for name@version by InstallPipeline
+func (i *Installer) StoreHash(name, version string) string {
+	if h, ok := i.storeHashes.Load(name + "@" + version); ok {
+		return h.(string)
+	}
+	return ""
 }
 
 func (i *Installer) InstallPackage(name, version string) (string, error) {
@@ -59,12 +111,15 @@ func (i *Installer) InstallPackage(name, version string) (string, error) {
 	cachePath := cache.GetCachePath(name, version)
 
 	if !cache.Exists(cachePath) {
-		metadata, err := registry.FetchMetadata(name, version)
+		metadata, err := i.metadata.Metadata(name, version)
 		if err != nil {
 			return "", fmt.Errorf("failed to fetch metadata: %w", err)
 		}
 
 		resolvedVersion = metadata.Version
+		if !platform.Matches(metadata.OS, metadata.CPU, metadata.Libc) {
+			return "", fmt.Errorf("%s@%s is not supported on %s/%s", name, resolvedVersion, platform.OS(), platform.CPU())
+		}
 		if i.debug {
 			ui.InstallStep("🔗", fmt.Sprintf("Tarball URL: %s", metadata.TarballURL))
 			ui.Muted.Printf("   Package: %s@%s (spec: %s)\n", name, resolvedVersion, version)
@@ -72,45 +127,22 @@ func (i *Installer) InstallPackage(name, version string) (string, error) {
 
 		cachePath = cache.GetCachePath(name, resolvedVersion)
 		if !cache.Exists(cachePath) {
-			stream, err := registry.StreamTarball(metadata.TarballURL)
-			if err != nil {
-				return "", fmt.Errorf("failed to stream tarball: %w", err)
-			}
-			defer stream.Close()
-			h := sha256.New()
-			tee := io.TeeReader(stream, h)
-			tmpDir, err := os.MkdirTemp("", "npgo-extract-*")
-			if err != nil {
-				return "", err
-			}
-			tmpPkg := filepath.Join(tmpDir, "package")
-			if err := extractor.ExtractFromReader(tee, tmpPkg); err != nil {
-				os.RemoveAll(tmpDir)
-				return "", err
-			}
-			hash := hex.EncodeToString(h.Sum(nil))
-			if i.debug {
-				ui.InstallStep("🔐", fmt.Sprintf("SHA256: %s", hash))
-			}
-			casPath, err := cas.EnsureDirs(hash)
-			if err != nil {
-				os.RemoveAll(tmpDir)
-				return "", err
-			}
-			exists, _ := cas.Exists(hash)
-			if !exists {
-				if err := os.MkdirAll(filepath.Dir(casPath), 0755); err != nil {
-					os.RemoveAll(tmpDir)
-					return "", err
+			hash, casPath, indexed := cas.LookupIndex(name, resolvedVersion, metadata.SRI())
+			if !indexed {
+				stream, err := i.tarballs.Tarball(name, resolvedVersion, metadata.TarballURL)
+				if err != nil {
+					return "", fmt.Errorf("failed to stream tarball: %w", err)
 				}
-				if err := os.Rename(tmpPkg, casPath); err != nil {
-					if err := createTreeLinkOrCopy(tmpPkg, casPath); err != nil {
-						os.RemoveAll(tmpDir)
-						return "", err
-					}
+				defer stream.Close()
+				hash, casPath, err = cas.ImportTarball(stream, metadata.SRI())
+				if err != nil {
+					return "", fmt.Errorf("%s@%s: %w", name, resolvedVersion, err)
 				}
+				_ = cas.RecordIndex(name, resolvedVersion, metadata.SRI(), hash)
+			}
+			if i.debug {
+				ui.InstallStep("🔐", fmt.Sprintf("SHA256: %s (verified %s, from store: %v)", hash, metadata.SRI(), indexed))
 			}
-			os.RemoveAll(tmpDir)
 			_, _ = cas.EnsureExtractedCache(hash)
 			extractPath := cache.GetExtractPath(name, metadata.Version)
 			if err := linkDirPreferSymlink(casPath, extractPath); err != nil {
@@ -340,8 +372,10 @@ func readIntegrity(dir string) (string, error) {
 }
 
 func (i *Installer) createSymlink(name, targetPath string) error {
-	linkPath := filepath.Join(i.nodeModulesPath, name)
+	return i.createSymlinkAt(filepath.Join(i.nodeModulesPath, name), targetPath)
+}
 
+func (i *Installer) createSymlinkAt(linkPath, targetPath string) error {
 	if _, err := os.Stat(targetPath); os.IsNotExist(err) {
 		return fmt.Errorf("target path does not exist: %s", targetPath)
 	}
@@ -351,8 +385,11 @@ func (i *Installer) createSymlink(name, targetPath string) error {
 			return fmt.Errorf("failed to remove existing link: %w", err)
 		}
 	}
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return err
+	}
 
-	relPath, err := filepath.Rel(i.nodeModulesPath, targetPath)
+	relPath, err := filepath.Rel(filepath.Dir(linkPath), targetPath)
 	if err == nil {
 		if err := os.Symlink(relPath, linkPath); err == nil {
 			return nil
@@ -383,6 +420,25 @@ func (i *Installer) createSymlink(name, targetPath string) error {
 	return copyDir(targetPath, linkPath)
 }
 
+// LinkWorkspacePackage symlinks a workspace member into this node_modules
+func (i *Installer) LinkWorkspacePackage(name, dir string) error {
+	return i.createSymlinkAt(filepath.Join(i.nodeModulesPath, filepath.FromSlash(name)), dir)
+}
+
+// placePackage puts a package at its layout path. Packages that host nested
+// node_modules are materialized as hard-linked trees: a symlink would make
+// Node resolve them from the shared store, where the nested copies are not.
+func (i *Installer) placePackage(relPath, extractPath string, host bool) error {
+	linkPath := filepath.Join(i.nodeModulesPath, filepath.FromSlash(relPath))
+	if !host {
+		return i.createSymlinkAt(linkPath, extractPath)
+	}
+	if err := os.RemoveAll(linkPath); err != nil {
+		return fmt.Errorf("failed to remove existing link: %w", err)
+	}
+	return copyDir(extractPath, linkPath)
+}
+
 func createJunctionWindows(linkPath, targetPath string) error {
 	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
 		return err
@@ -518,7 +574,8 @@ func (i *Installer) Clean() error {
 	return nil
 }
 
-// InstallPipeline installs packages using two-stage pipeline: download/extract → link
+// InstallPipeline installs packages using a three-stage pipeline:
+// download/extract → link top-level → place nested copies level by level
 func (i *Installer) InstallPipeline(pkgs []PackageSpec, downloadWorkers, linkWorkers int) error {
 	if downloadWorkers <= 0 {
 		downloadWorkers = 8
@@ -527,67 +584,89 @@ func (i *Installer) InstallPipeline(pkgs []PackageSpec, downloadWorkers, linkWor
 		linkWorkers = 8
 	}
 
-	type linkItem struct{ name, version, casPath string }
-	dlJobs := make(chan PackageSpec, len(pkgs))
-	linkJobs := make(chan linkItem, len(pkgs))
-	errs := make(chan error, len(pkgs))
+	// the same name@version may be placed at several paths; download it once
+	placements := make(map[string][]PackageSpec)
+	unique := make([]PackageSpec, 0, len(pkgs))
+	optional := make(map[string]bool)
+	hosts := make(map[string]bool)
+	linked := make(map[string]bool)
+	for _, p := range pkgs {
+		if p.Linked {
+			linked[p.installPath()] = true
+		}
+	}
+	for _, p := range pkgs {
+		key := p.key()
+		if _, ok := placements[key]; !ok {
+			unique = append(unique, p)
+			optional[key] = p.Optional
+		}
+		optional[key] = optional[key] && p.Optional
+		placements[key] = append(placements[key], p)
+		// nested children of a linked package go through the symlink into its own node_modules
+		if parent := layoutParent(p.installPath()); parent != "" && !linked[parent] {
+			hosts[parent] = true
+		}
+	}
+
+	type linkItem struct{ key, name, version, casPath string }
+	type nestedItem struct{ path, extractPath string }
+	dlJobs := make(chan PackageSpec, len(unique))
+	linkJobs := make(chan linkItem, len(unique))
+	errs := make(chan error, len(pkgs)+len(unique))
 
 	var wgDL sync.WaitGroup
 	var wgLink sync.WaitGroup
+	var nestedMu sync.Mutex
+	var nested []nestedItem
 
 	// stage 1: download+extract to CAS
+	// optional packages are dropped with a warning instead of failing the
+	// install; skipped records their paths so nothing is nested beneath them
+	var skippedMu sync.Mutex
+	skipped := make(map[string]bool)
+	fail := func(p PackageSpec, err error) {
+		if optional[p.key()] {
+			ui.Warning.Printf("⚠️  skipping optional %s@%s: %v\n", p.Name, p.Version, err)
+			skippedMu.Lock()
+			for _, sp := range placements[p.key()] {
+				skipped[sp.installPath()] = true
+			}
+			skippedMu.Unlock()
+			return
+		}
+		errs <- err
+	}
+
 	dlWorker := func() {
 		defer wgDL.Done()
 		for p := range dlJobs {
-			// Ensure CAS path via single-pass pipeline
-			// Fast path: if CAS already has content, skip
-			// Hash requires downloading; we attempt metadata tarball stream
-			stream, err := registry.StreamTarball(p.TarballURL)
-			if err != nil {
-				errs <- fmt.Errorf("failed to stream %s: %w", p.Name, err)
+			if p.LocalPath != "" {
+				linkJobs <- linkItem{key: p.key(), name: p.Name, version: p.Version, casPath: p.LocalPath}
 				continue
 			}
-			h := sha256.New()
-			tee := io.TeeReader(stream, h)
-			tmpDir, err := os.MkdirTemp("", "npgo-extract-*")
-			if err != nil {
-				stream.Close()
-				errs <- err
+			// fast path: the store index already knows this exact tarball
+			if hash, casPath, ok := cas.LookupIndex(p.Name, p.Version, p.Integrity); ok {
+				i.storeHashes.Store(p.Name+"@"+p.Version, hash)
+				linkJobs <- linkItem{key: p.key(), name: p.Name, version: p.Version, casPath: casPath}
 				continue
 			}
-			tmpPkg := filepath.Join(tmpDir, "package")
-			if err := extractor.ExtractFromReader(tee, tmpPkg); err != nil {
-				stream.Close()
-				os.RemoveAll(tmpDir)
-				errs <- err
+			stream, err := i.tarballs.Tarball(p.Name, p.Version, p.TarballURL)
+			if err != nil {
+				fail(p, fmt.Errorf("failed to stream %s: %w", p.Name, err))
 				continue
 			}
+			// integrity is checked before the extraction enters the CAS
+			hash, casPath, err := cas.ImportTarball(stream, p.Integrity)
 			stream.Close()
-			hash := hex.EncodeToString(h.Sum(nil))
-			casPath, err := cas.EnsureDirs(hash)
 			if err != nil {
-				os.RemoveAll(tmpDir)
-				errs <- err
+				fail(p, fmt.Errorf("%s@%s: %w", p.Name, p.Version, err))
 				continue
 			}
-			exists, _ := cas.Exists(hash)
-			if !exists {
-				if err := os.MkdirAll(filepath.Dir(casPath), 0755); err != nil {
-					os.RemoveAll(tmpDir)
-					errs <- err
-					continue
-				}
-				if err := os.Rename(tmpPkg, casPath); err != nil {
-					if err := createTreeLinkOrCopy(tmpPkg, casPath); err != nil {
-						os.RemoveAll(tmpDir)
-						errs <- err
-						continue
-					}
-				}
-			}
-			os.RemoveAll(tmpDir)
+			i.storeHashes.Store(p.Name+"@"+p.Version, hash)
+			_ = cas.RecordIndex(p.Name, p.Version, p.Integrity, hash)
 			_, _ = cas.EnsureExtractedCache(hash)
-			linkJobs <- linkItem{name: p.Name, version: p.Version, casPath: casPath}
+			linkJobs <- linkItem{key: p.key(), name: p.Name, version: p.Version, casPath: casPath}
 		}
 	}
 
@@ -595,18 +674,34 @@ func (i *Installer) InstallPipeline(pkgs []PackageSpec, downloadWorkers, linkWor
 	linkWorker := func() {
 		defer wgLink.Done()
 		for it := range linkJobs {
-			extractPath := cache.GetExtractPath(it.name, it.version)
-			if err := linkDirPreferSymlink(it.casPath, extractPath); err != nil {
-				errs <- err
-				continue
+			local := placements[it.key][0].LocalPath != ""
+			extractPath := it.casPath
+			if !local {
+				extractPath = cache.GetExtractPath(it.name, it.version)
+				if err := linkDirPreferSymlink(it.casPath, extractPath); err != nil {
+					errs <- err
+					continue
+				}
 			}
-			_ = ensureGlobalPackageLink(it.name, extractPath)
-			_ = i.linkPackageBinaries(it.name, extractPath)
-			_ = writeIntegrity(filepath.Join(i.nodeModulesPath, it.name), it.name, it.version, "")
-			// symlink node_modules/<name> → extractPath
-			if err := i.createSymlink(it.name, extractPath); err != nil {
-				errs <- err
-				continue
+			for _, p := range placements[it.key] {
+				path := p.installPath()
+				if layoutDepth(path) > 0 {
+					nestedMu.Lock()
+					nested = append(nested, nestedItem{path: path, extractPath: extractPath})
+					nestedMu.Unlock()
+					continue
+				}
+				if !local {
+					_ = ensureGlobalPackageLink(it.name, extractPath)
+				}
+				_ = i.linkPackageBinaries(it.name, extractPath)
+				if !p.Linked {
+					_ = writeIntegrity(filepath.Join(i.nodeModulesPath, it.name), it.name, it.version, "")
+				}
+				// node_modules/<name> → extractPath
+				if err := i.placePackage(path, extractPath, hosts[path]); err != nil {
+					errs <- err
+				}
 			}
 		}
 	}
@@ -620,7 +715,7 @@ func (i *Installer) InstallPipeline(pkgs []PackageSpec, downloadWorkers, linkWor
 		go linkWorker()
 	}
 
-	for _, p := range pkgs {
+	for _, p := range unique {
 		dlJobs <- p
 	}
 	close(dlJobs)
@@ -628,6 +723,45 @@ func (i *Installer) InstallPipeline(pkgs []PackageSpec, downloadWorkers, linkWor
 	close(linkJobs)
 	wgLink.Wait()
 
+	// stage 3: nested copies, shallowest first so hosts exist before their
+	// children; copies under a skipped optional package have no host
+	kept := nested[:0]
+	for _, it := range nested {
+		orphan := false
+		for parent := layoutParent(it.path); parent != "" && !orphan; parent = layoutParent(parent) {
+			orphan = skipped[parent]
+		}
+		if !orphan {
+			kept = append(kept, it)
+		}
+	}
+	nested = kept
+	sort.Slice(nested, func(a, b int) bool {
+		return layoutDepth(nested[a].path) < layoutDepth(nested[b].path)
+	})
+	for start := 0; start < len(nested); {
+		end := start
+		for end < len(nested) && layoutDepth(nested[end].path) == layoutDepth(nested[start].path) {
+			end++
+		}
+		sem := make(chan struct{}, linkWorkers)
+		var wg sync.WaitGroup
+		for _, it := range nested[start:end] {
+			it := it
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := i.placePackage(it.path, it.extractPath, hosts[it.path]); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		start = end
+	}
+
 	close(errs)
 	for err := range errs {
 		if err != nil {
@@ -0,0 +1,172 @@
+package installer
+
+import (
+	"sort"
+	"strings"
+
+	"npgo/internal/resolver"
+)
+
+// layoutLevel is one node_modules directory in the planned tree
+type layoutLevel struct {
+	parent   *layoutLevel
+	dir      string // relative to the root node_modules; "" for the root
+	packages map[string]*placement
+}
+
+type placement struct {
+	dep    *resolver.Dependency
+	path   string
+	own    *layoutLevel // <path>/node_modules
+	parent *placement   // dependent that pulled this placement in
+	// optional is true while every edge reaching this placement is optional
+	optional bool
+}
+
+// lookupRecord remembers that a require(name) issued from `from` resolved to `at`
+type lookupRecord struct {
+	from, at *layoutLevel
+	name     string
+}
+
+// PlanLayout computes a hoisted node_modules layout. Every package is placed
+// as high as possible; a version that conflicts with one already visible to
+// the dependent is nested under the dependent's own node_modules so that
+// Node's lookup finds the version it declared. lookup supplies the roots;
+// below them the graph's linked edges are followed, so override-scoped copies
+// are placed where they apply.
+func PlanLayout(roots map[string]string, lookup func(name, spec string) *resolver.Dependency) []PackageSpec {
+	root := &layoutLevel{packages: make(map[string]*placement)}
+	var queue []*placement
+	var records []lookupRecord
+	var placed []*placement
+
+	place := func(at *layoutLevel, name string, dep *resolver.Dependency, parent *placement, optional bool) *placement {
+		p := &placement{dep: dep, path: name, parent: parent, optional: optional}
+		if at.dir != "" {
+			p.path = at.dir + "/" + name
+		}
+		p.own = &layoutLevel{parent: at, dir: p.path + "/node_modules", packages: make(map[string]*placement)}
+		at.packages[name] = p
+		placed = append(placed, p)
+		queue = append(queue, p)
+		return p
+	}
+
+	for _, name := range sortedKeys(roots) {
+		if dep := lookup(name, roots[name]); dep != nil {
+			place(root, name, dep, nil, false)
+		}
+	}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, cn := range sortedKeys(p.dep.RawDeps) {
+			child := p.dep.Dependencies[cn]
+			if child == nil {
+				continue
+			}
+			from := p.own
+			optional := p.optional || p.dep.OptionalDeps[cn]
+
+			// nearest visible copy: reuse it, or remember where it blocks hoisting
+			var conflict *layoutLevel
+			satisfied := false
+			for l := from; l != nil; l = l.parent {
+				if ex, ok := l.packages[cn]; ok {
+					if ex.dep == child || (ex.dep.Resolved == child.Resolved && ex.dep.LocalPath == child.LocalPath && ex.dep.Scope == child.Scope) {
+						ex.optional = ex.optional && optional
+						records = append(records, lookupRecord{from: from, at: l, name: cn})
+						satisfied = true
+					} else {
+						conflict = l
+					}
+					break
+				}
+			}
+			if satisfied {
+				continue
+			}
+			// a dependency cycle that the lookup from here does not close is
+			// unrolled with a copy of the ancestor; twice means it never will
+			if ancestorCopies(p, child) >= 2 {
+				continue
+			}
+
+			target := from
+			var candidates []*layoutLevel
+			for l := from; l != conflict; l = l.parent {
+				candidates = append(candidates, l)
+			}
+			for k := len(candidates) - 1; k >= 0; k-- {
+				if !shadowsLookup(records, candidates[k], cn) {
+					target = candidates[k]
+					break
+				}
+			}
+			place(target, cn, child, p, optional)
+			records = append(records, lookupRecord{from: from, at: target, name: cn})
+		}
+	}
+
+	specs := make([]PackageSpec, 0, len(placed))
+	for _, p := range placed {
+		specs = append(specs, PackageSpec{Name: p.dep.Name, Version: p.dep.Resolved, TarballURL: p.dep.TarballURL, Integrity: p.dep.Integrity, Path: p.path, Optional: p.optional, LocalPath: p.dep.LocalPath, Linked: p.dep.Linked})
+	}
+	return specs
+}
+
+// ancestorCopies counts the placements of dep among p and its dependents,
+// which stops dependency cycles from nesting forever
+func ancestorCopies(p *placement, dep *resolver.Dependency) int {
+	n := 0
+	for a := p; a != nil; a = a.parent {
+		if a.dep.Name == dep.Name && a.dep.Resolved == dep.Resolved && a.dep.Scope == dep.Scope {
+			n++
+		}
+	}
+	return n
+}
+
+// shadowsLookup reports whether placing name at level would hide a copy that
+// a package below level already resolved from further up the tree.
+func shadowsLookup(records []lookupRecord, level *layoutLevel, name string) bool {
+	for _, r := range records {
+		if r.name == name && within(r.from, level) && !within(r.at, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func within(l, ancestor *layoutLevel) bool {
+	for ; l != nil; l = l.parent {
+		if l == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// layoutDepth counts how many node_modules levels a layout path is nested under
+func layoutDepth(path string) int {
+	return strings.Count(path, "/node_modules/")
+}
+
+// layoutParent returns the path of the package hosting a nested path, or ""
+func layoutParent(path string) string {
+	if idx := strings.LastIndex(path, "/node_modules/"); idx >= 0 {
+		return path[:idx]
+	}
+	return ""
+}
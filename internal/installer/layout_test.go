@@ -0,0 +1,104 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	"npgo/internal/registry"
+	"npgo/internal/resolver"
+)
+
+func layoutRegistry(t *testing.T) *registry.Memory {
+	t.Helper()
+	mem := registry.NewMemory()
+	for _, md := range []registry.PackageMetadata{
+		{Name: "a", Version: "1.0.0", Dependencies: map[string]string{"c": "^1.0.0", "d": "^1.0.0"}},
+		{Name: "b", Version: "1.0.0", Dependencies: map[string]string{"c": "^2.0.0", "d": "~1.1.0"}},
+		{Name: "c", Version: "1.0.0"},
+		{Name: "c", Version: "1.1.0"},
+		{Name: "c", Version: "2.0.0", Dependencies: map[string]string{"d": "^1.2.0"}},
+		{Name: "c", Version: "3.0.0-beta.1"},
+		{Name: "d", Version: "1.0.0"},
+		{Name: "d", Version: "1.1.0"},
+		{Name: "d", Version: "1.2.0"},
+		{Name: "e", Version: "1.0.0", Dependencies: map[string]string{"f": "1.0.0"}},
+		{Name: "f", Version: "1.0.0", Dependencies: map[string]string{"e": "^1"}},
+		{Name: "p", Version: "1.0.0"},
+		{Name: "p", Version: "2.0.0", Dependencies: map[string]string{"r": "^1"}},
+		{Name: "q", Version: "1.0.0", Dependencies: map[string]string{"p": "^2"}},
+		{Name: "r", Version: "1.0.0", Dependencies: map[string]string{"p": "^2"}},
+	} {
+		if err := mem.Add(md, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mem
+}
+
+func TestPlanLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		roots map[string]string
+		want  map[string]string // install path → version
+	}{
+		{
+			name:  "highest satisfying versions are hoisted",
+			roots: map[string]string{"a": "^1"},
+			want:  map[string]string{"a": "1.0.0", "c": "1.1.0", "d": "1.2.0"},
+		},
+		{
+			name:  "conflicting version nests under its dependent",
+			roots: map[string]string{"a": "^1", "b": "^1"},
+			want: map[string]string{
+				"a": "1.0.0", "b": "1.0.0", "c": "1.1.0", "d": "1.2.0",
+				"b/node_modules/c": "2.0.0", "b/node_modules/d": "1.1.0",
+				// b's own d would shadow the hoisted one c@2 needs
+				"b/node_modules/c/node_modules/d": "1.2.0",
+			},
+		},
+		{
+			name:  "direct dependency keeps the top level",
+			roots: map[string]string{"a": "^1", "c": "2"},
+			want: map[string]string{
+				"a": "1.0.0", "c": "2.0.0", "d": "1.2.0",
+				"a/node_modules/c": "1.1.0",
+			},
+		},
+		{
+			name:  "prereleases need an explicit range",
+			roots: map[string]string{"c": "*"},
+			want:  map[string]string{"c": "2.0.0", "d": "1.2.0"},
+		},
+		{
+			name:  "cycles are placed once",
+			roots: map[string]string{"e": "1.0.0"},
+			want:  map[string]string{"e": "1.0.0", "f": "1.0.0"},
+		},
+		{
+			// r is hoisted above the nested p@2 it cycles back to, so it
+			// needs its own copy rather than the root p@1
+			name:  "cycle through a nested copy stays in range",
+			roots: map[string]string{"p": "^1", "q": "^1"},
+			want: map[string]string{
+				"p": "1.0.0", "q": "1.0.0", "r": "1.0.0",
+				"q/node_modules/p": "2.0.0", "r/node_modules/p": "2.0.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := resolver.NewResolver()
+			r.SetMetadataSource(layoutRegistry(t))
+			if _, err := r.BuildGraph(tt.roots); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, p := range PlanLayout(tt.roots, r.Lookup) {
+				got[p.installPath()] = p.Version
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlanLayout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,20 +4,66 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	yaml "gopkg.in/yaml.v3"
 )
 
+// Version is the lockfile format written by Save
+const Version = 2
+
 type PackageEntry struct {
-	Name      string `yaml:"name"`
-	Version   string `yaml:"version"`
-	Resolved  string `yaml:"resolved"`
-	Integrity string `yaml:"integrity"`
+	Name     string `yaml:"name"`
+	Version  string `yaml:"version"`
+	Resolved string `yaml:"resolved"`
+	// Integrity is the registry SRI (e.g. "sha512-…"); StoreHash is the
+	// sha256 the tarball or package directory is stored under in the CAS
+	Integrity string `yaml:"integrity,omitempty"`
+	StoreHash string `yaml:"storeHash,omitempty"`
+	// Override is the overrides/resolutions selector that forced this version
+	Override string `yaml:"override,omitempty"`
+	// Scope tells apart a copy resolved under nested override selectors
+	// from the shared entry of the same version
+	Scope string `yaml:"scope,omitempty"`
+	// Source pins non-registry packages: git URL#sha, tarball URL, file:, link: or npm: alias
+	Source string `yaml:"source,omitempty"`
+	// Dependencies are the package's resolved dependency edges by name
+	Dependencies     map[string]Edge   `yaml:"dependencies,omitempty"`
+	OS               []string          `yaml:"os,omitempty"`
+	CPU              []string          `yaml:"cpu,omitempty"`
+	Libc             []string          `yaml:"libc,omitempty"`
+	Engines          map[string]string `yaml:"engines,omitempty"`
+	Bin              map[string]string `yaml:"bin,omitempty"`
+	HasInstallScript bool              `yaml:"hasInstallScript,omitempty"`
+	Deprecated       string            `yaml:"deprecated,omitempty"`
+}
+
+// Edge is one dependency edge: the spec as requested and the version it got.
+// Override is the spec an overrides/resolutions rule replaced Spec with.
+type Edge struct {
+	Spec     string `yaml:"spec"`
+	Override string `yaml:"override,omitempty"`
+	Version  string `yaml:"version"`
+	Scope    string `yaml:"scope,omitempty"`
+	Optional bool   `yaml:"optional,omitempty"`
+}
+
+// Importer is a package.json the lockfile was resolved from: the project
+// root (".") or a workspace member (its directory relative to the root)
+type Importer struct {
+	Dependencies map[string]Edge `yaml:"dependencies,omitempty"`
 }
 
 type LockFile struct {
-	LockfileVersion int            `yaml:"lockfileVersion"`
-	Packages        []PackageEntry `yaml:"packages"`
+	LockfileVersion int `yaml:"lockfileVersion"`
+	// OverridesHash fingerprints the overrides/resolutions the graph was
+	// resolved with; a lockfile whose hash no longer matches is stale
+	OverridesHash string              `yaml:"overridesHash,omitempty"`
+	Importers     map[string]Importer `yaml:"importers,omitempty"`
+	// Dependencies holds the root edges of version 1 files; Load moves them
+	// into Importers["."]
+	Dependencies map[string]Edge `yaml:"dependencies,omitempty"`
+	Packages     []PackageEntry  `yaml:"packages"`
 }
 
 func Path(projectDir string) string {
@@ -32,12 +78,40 @@ func Load(projectDir string) (*LockFile, error) {
 	}
 	var lf LockFile
 	if err := yaml.Unmarshal(data, &lf); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to parse %s: %w", p, err)
+	}
+	if lf.LockfileVersion > Version {
+		return nil, fmt.Errorf("%s has lockfileVersion %d; this npgo understands up to %d", p, lf.LockfileVersion, Version)
+	}
+	if lf.LockfileVersion < Version {
+		migrateV1(&lf)
 	}
 	return &lf, nil
 }
 
+// migrateV1 upgrades a version 1 lockfile in memory. Version 1 kept a
+// placeholder integrity and no importers; workspace members are recovered
+// from their "workspace:<dir>" sources.
+func migrateV1(lf *LockFile) {
+	lf.Importers = make(map[string]Importer)
+	if lf.Dependencies != nil {
+		lf.Importers["."] = Importer{Dependencies: lf.Dependencies}
+		lf.Dependencies = nil
+	}
+	for i := range lf.Packages {
+		e := &lf.Packages[i]
+		if e.Integrity == "sha256" {
+			e.Integrity = ""
+		}
+		if dir := strings.TrimPrefix(e.Source, "workspace:"); dir != e.Source {
+			lf.Importers[dir] = Importer{Dependencies: e.Dependencies}
+		}
+	}
+	lf.LockfileVersion = Version
+}
+
 func Save(projectDir string, lf *LockFile) error {
+	lf.LockfileVersion = Version
 	data, err := yaml.Marshal(lf)
 	if err != nil {
 		return err
@@ -0,0 +1,107 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	want := &LockFile{
+		OverridesHash: "abc123",
+		Importers: map[string]Importer{
+			".":            {Dependencies: map[string]Edge{"a": {Spec: "^1.0.0", Version: "1.2.0"}}},
+			"packages/app": {Dependencies: map[string]Edge{"b": {Spec: "^2", Version: "2.0.3"}}},
+		},
+		Packages: []PackageEntry{
+			{
+				Name: "a", Version: "1.2.0", Resolved: "https://registry.npmjs.org/a/-/a-1.2.0.tgz",
+				Integrity: "sha512-AAAA", StoreHash: "0123",
+				Dependencies: map[string]Edge{
+					"b": {Spec: "~2.0.0", Override: "2.0.3", Version: "2.0.3", Scope: "overrides[a>b]"},
+					"c": {Spec: "^1", Version: "1.0.0", Optional: true},
+				},
+				OS: []string{"linux"}, Engines: map[string]string{"node": ">=18"},
+				Bin: map[string]string{"a": "bin/a.js"}, HasInstallScript: true,
+			},
+			{Name: "b", Version: "2.0.3", Override: "overrides[a>b]", Scope: "overrides[a>b]", Deprecated: "use c"},
+			{Name: "c", Version: "1.0.0", Source: "git+https://example.invalid/c.git#0123456789abcdef0123456789abcdef01234567"},
+		},
+	}
+	if err := Save(dir, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LockfileVersion != Version {
+		t.Errorf("LockfileVersion = %d, want %d", got.LockfileVersion, Version)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLoadV1(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "v1.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(Path(dir), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	lf, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lf.LockfileVersion != Version || lf.Dependencies != nil {
+		t.Errorf("LockfileVersion = %d, Dependencies = %v", lf.LockfileVersion, lf.Dependencies)
+	}
+	want := map[string]Importer{
+		".": {Dependencies: map[string]Edge{
+			"a":   {Spec: "^1.0.0", Version: "1.2.0"},
+			"app": {Spec: "*", Version: "0.1.0"},
+		}},
+		"packages/app": {Dependencies: map[string]Edge{"b": {Spec: "^2", Version: "2.0.3"}}},
+	}
+	if !reflect.DeepEqual(lf.Importers, want) {
+		t.Errorf("Importers = %+v, want %+v", lf.Importers, want)
+	}
+	integrity := map[string]string{}
+	for _, e := range lf.Packages {
+		integrity[e.Name] = e.Integrity
+	}
+	// the v1 placeholder is dropped, real SRIs are kept
+	if want := map[string]string{"a": "", "b": "sha512-AAAA", "app": ""}; !reflect.DeepEqual(integrity, want) {
+		t.Errorf("integrity = %v, want %v", integrity, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"newer version", "lockfileVersion: 99\npackages: []\n", "lockfileVersion 99"},
+		{"invalid YAML", "lockfileVersion: [\n", "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(Path(dir), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := Load(t.TempDir()); !os.IsNotExist(err) {
+		t.Errorf("Load without a lockfile = %v, want a not-exist error", err)
+	}
+}
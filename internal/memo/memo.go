@@ -0,0 +1,96 @@
+package memo
+
+import "sync"
+
+// Map is a concurrent memo keyed by string. The first Do for a key runs fn;
+// callers that arrive while it is in flight wait for the same result instead
+// of repeating the work. Successful results are kept, errors are not, so a
+// later Do retries. The zero Map is ready to use.
+type Map[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// Do returns the memoized value for key, computing it with fn at most once
+// at a time
+func (m *Map[V]) Do(key string, fn func() (V, error)) (V, error) {
+	m.mu.Lock()
+	if m.calls == nil {
+		m.calls = make(map[string]*call[V])
+	}
+	if c, ok := m.calls[key]; ok {
+		m.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	c := &call[V]{done: make(chan struct{})}
+	m.calls[key] = c
+	m.mu.Unlock()
+
+	c.val, c.err = fn()
+	if c.err != nil {
+		m.mu.Lock()
+		delete(m.calls, key)
+		m.mu.Unlock()
+	}
+	close(c.done)
+	return c.val, c.err
+}
+
+// Get returns the value stored for key, if it has completed successfully
+func (m *Map[V]) Get(key string) (V, bool) {
+	m.mu.Lock()
+	c, ok := m.calls[key]
+	m.mu.Unlock()
+	if ok {
+		select {
+		case <-c.done:
+			if c.err == nil {
+				return c.val, true
+			}
+		default:
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Store sets the value for key, replacing any earlier result
+func (m *Map[V]) Store(key string, v V) {
+	c := &call[V]{done: make(chan struct{}), val: v}
+	close(c.done)
+	m.mu.Lock()
+	if m.calls == nil {
+		m.calls = make(map[string]*call[V])
+	}
+	m.calls[key] = c
+	m.mu.Unlock()
+}
+
+// Range calls fn for every completed value until fn returns false. fn may
+// call Store.
+func (m *Map[V]) Range(fn func(key string, v V) bool) {
+	m.mu.Lock()
+	done := make(map[string]V, len(m.calls))
+	for k, c := range m.calls {
+		select {
+		case <-c.done:
+			if c.err == nil {
+				done[k] = c.val
+			}
+		default:
+		}
+	}
+	m.mu.Unlock()
+	for k, v := range done {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
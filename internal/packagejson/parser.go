@@ -14,7 +14,28 @@ type PackageJSON struct {
 	DevDependencies map[string]string `json:"devDependencies,omitempty"`
 	Scripts         map[string]string `json:"scripts,omitempty"`
 	Private         bool              `json:"private,omitempty"`
-	Workspaces      interface{}       `json:"workspaces,omitempty"`
+	// Engines is usually an object; a few old packages publish an array
+	Engines    interface{} `json:"engines,omitempty"`
+	Workspaces interface{} `json:"workspaces,omitempty"`
+	// Overrides (npm) values are either a spec or a nested object of overrides
+	Overrides   map[string]interface{} `json:"overrides,omitempty"`
+	Resolutions map[string]string      `json:"resolutions,omitempty"`
+}
+
+// EngineRanges returns the engines field as name → range; the legacy array
+// form carries no names and yields nil
+func (p *PackageJSON) EngineRanges() map[string]string {
+	obj, ok := p.Engines.(map[string]interface{})
+	if !ok || len(obj) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(obj))
+	for k, v := range obj {
+		if s, ok := v.(string); ok {
+			out[k] = s
+		}
+	}
+	return out
 }
 
 func Read(path string) (*PackageJSON, error) {
@@ -58,6 +79,25 @@ func (p *PackageJSON) GetDependencies() map[string]string {
 	return deps
 }
 
+// WorkspacePatterns returns the workspace globs from either the array form
+// or the {"packages": [...]} object form
+func (p *PackageJSON) WorkspacePatterns() []string {
+	var list []interface{}
+	switch v := p.Workspaces.(type) {
+	case []interface{}:
+		list = v
+	case map[string]interface{}:
+		list, _ = v["packages"].([]interface{})
+	}
+	patterns := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok && s != "" {
+			patterns = append(patterns, s)
+		}
+	}
+	return patterns
+}
+
 func (p *PackageJSON) HasDependencies() bool {
 	return len(p.Dependencies) > 0 || len(p.DevDependencies) > 0
 }
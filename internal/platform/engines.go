@@ -0,0 +1,44 @@
+package platform
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+
+	"npgo/internal/semver"
+)
+
+var (
+	nodeOnce    sync.Once
+	nodeVersion string
+)
+
+// NodeVersion returns the version of the node binary on PATH (e.g. "20.11.0"),
+// or "" when node is not installed
+func NodeVersion() string {
+	nodeOnce.Do(func() {
+		path, err := exec.LookPath("node")
+		if err != nil {
+			return
+		}
+		out, err := exec.Command(path, "--version").Output()
+		if err != nil {
+			return
+		}
+		v := strings.TrimPrefix(strings.TrimSpace(string(out)), "v")
+		if semver.Valid(v) {
+			nodeVersion = v
+		}
+	})
+	return nodeVersion
+}
+
+// EngineSatisfied reports whether version have satisfies an engines range.
+// Ranges that do not parse are ignored, as npm does.
+func EngineSatisfied(rng, have string) bool {
+	rng = strings.TrimSpace(rng)
+	if rng == "" || !semver.ValidRange(rng) {
+		return true
+	}
+	return semver.Satisfies(have, rng)
+}
@@ -0,0 +1,93 @@
+package platform
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var nodeOS = map[string]string{
+	"windows": "win32",
+	"solaris": "sunos",
+	"illumos": "sunos",
+}
+
+var nodeArch = map[string]string{
+	"amd64":    "x64",
+	"386":      "ia32",
+	"ppc64le":  "ppc64",
+	"mipsle":   "mipsel",
+	"mips64le": "mips64el",
+}
+
+// OS returns the host platform using Node's process.platform names
+func OS() string {
+	if v, ok := nodeOS[runtime.GOOS]; ok {
+		return v
+	}
+	return runtime.GOOS
+}
+
+// CPU returns the host architecture using Node's process.arch names
+func CPU() string {
+	if v, ok := nodeArch[runtime.GOARCH]; ok {
+		return v
+	}
+	return runtime.GOARCH
+}
+
+var (
+	libcOnce sync.Once
+	libc     string
+)
+
+// Libc returns "glibc" or "musl" on Linux and "" elsewhere
+func Libc() string {
+	libcOnce.Do(func() {
+		if runtime.GOOS != "linux" {
+			return
+		}
+		if m, _ := filepath.Glob("/lib/ld-musl-*.so.1"); len(m) > 0 {
+			libc = "musl"
+			return
+		}
+		libc = "glibc"
+	})
+	return libc
+}
+
+// Matches checks a package's os/cpu/libc fields against the host
+func Matches(osList, cpuList, libcList []string) bool {
+	if !allowed(osList, OS()) || !allowed(cpuList, CPU()) {
+		return false
+	}
+	if l := Libc(); l != "" && !allowed(libcList, l) {
+		return false
+	}
+	return true
+}
+
+// allowed applies npm's rules: "!x" blocks x, and a non-empty allow list
+// must contain the host value.
+func allowed(list []string, host string) bool {
+	hasAllow := false
+	for _, v := range list {
+		if strings.HasPrefix(v, "!") {
+			if v[1:] == host {
+				return false
+			}
+			continue
+		}
+		hasAllow = true
+	}
+	if !hasAllow {
+		return true
+	}
+	for _, v := range list {
+		if v == host || v == "any" {
+			return true
+		}
+	}
+	return false
+}
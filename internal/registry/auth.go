@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewRegistryFromConfig builds a registry from npm-style settings (keys in
+// lower case, as config.Config.All returns them):
+//
+//	registry=https://registry.example.com/
+//	@ourco:registry=https://npm.ourco.dev/
+//	//npm.ourco.dev/:_authToken=...
+//	//registry.example.com/:_auth=base64(user:pass)
+//	//registry.example.com/:username=... and :_password=base64(pass)
+func NewRegistryFromConfig(settings map[string]string) *Registry {
+	g := NewRegistry(DefaultURL)
+	if u := settings["registry"]; u != "" {
+		g.URL = strings.TrimSuffix(u, "/")
+	}
+	g.Scopes = make(map[string]string)
+	g.auth = make(map[string]string)
+	users := make(map[string]string)
+	passwords := make(map[string]string)
+	for k, v := range settings {
+		if strings.HasPrefix(k, "@") && strings.HasSuffix(k, ":registry") {
+			g.Scopes[strings.TrimSuffix(k, ":registry")] = strings.TrimSuffix(v, "/")
+			continue
+		}
+		if !strings.HasPrefix(k, "//") {
+			continue
+		}
+		i := strings.LastIndex(k, ":")
+		if i < 0 {
+			continue
+		}
+		prefix, field := k[:i], k[i+1:]
+		switch field {
+		case "_authtoken":
+			g.auth[prefix] = "Bearer " + v
+		case "_auth":
+			if _, ok := g.auth[prefix]; !ok {
+				g.auth[prefix] = "Basic " + v
+			}
+		case "username":
+			users[prefix] = v
+		case "_password":
+			passwords[prefix] = v
+		}
+	}
+	for prefix, user := range users {
+		if _, ok := g.auth[prefix]; ok {
+			continue
+		}
+		pass, err := base64.StdEncoding.DecodeString(passwords[prefix])
+		if err != nil {
+			continue
+		}
+		g.auth[prefix] = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+string(pass)))
+	}
+	return g
+}
+
+// registryFor returns the registry a package is served from: its scope's
+// registry when one is configured, otherwise the default
+func (g *Registry) registryFor(pkgName string) string {
+	if strings.HasPrefix(pkgName, "@") {
+		if i := strings.Index(pkgName, "/"); i > 0 {
+			if u, ok := g.Scopes[pkgName[:i]]; ok {
+				return u
+			}
+		}
+	}
+	return g.URL
+}
+
+// cacheNamespace is the registry-cache subdirectory for the registry serving
+// pkgName: its nerf dart with separators replaced, e.g.
+// "registry.npmjs.org" or "npm.example.com_8443_team", so that registries
+// never share cached documents
+func (g *Registry) cacheNamespace(pkgName string) string {
+	u, err := url.Parse(g.registryFor(pkgName))
+	if err != nil || u.Host == "" {
+		return "_invalid"
+	}
+	dart := strings.Trim(nerfDart(u), "/")
+	return strings.NewReplacer("/", "_", ":", "_").Replace(dart)
+}
+
+// packumentURL escapes the scope separator the way npm does
+func (g *Registry) packumentURL(pkgName string) string {
+	return g.registryFor(pkgName) + "/" + strings.Replace(pkgName, "/", "%2f", 1)
+}
+
+// authorize sets the Authorization header of the most specific credentials
+// whose "//host/path" prefix matches the request URL
+func (g *Registry) authorize(req *http.Request) {
+	if len(g.auth) == 0 {
+		return
+	}
+	target := nerfDart(req.URL)
+	best := ""
+	for prefix := range g.auth {
+		p := strings.TrimSuffix(prefix, "/") + "/"
+		if strings.HasPrefix(target, p) && len(p) > len(best) {
+			best = prefix
+		}
+	}
+	if best != "" {
+		req.Header.Set("Authorization", g.auth[best])
+	}
+}
+
+// nerfDart strips the scheme, query and credentials: "//host[:port]/path/"
+func nerfDart(u *url.URL) string {
+	path := u.EscapedPath()
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return strings.ToLower("//" + u.Host + path)
+}
@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+
+	"npgo/internal/semver"
+)
+
+var publishedBefore time.Time
+
+// SetPublishedBefore hides versions published at or after t from range and
+// dist-tag resolution (--before, minimumReleaseAge). The zero time disables it.
+// Exact versions are never filtered.
+func SetPublishedBefore(t time.Time) {
+	publishedBefore = t
+}
+
+// PublishedBefore returns the active publish cutoff, or the zero time
+func PublishedBefore() time.Time {
+	return publishedBefore
+}
+
+// published reports whether v may be picked under the cutoff. Versions the
+// packument has no publish time for are kept, since they cannot be dated.
+func (p *Packument) published(v string) bool {
+	if publishedBefore.IsZero() {
+		return true
+	}
+	ts, ok := p.Time[v]
+	if !ok {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return true
+	}
+	return t.Before(publishedBefore)
+}
+
+// tagBefore returns the dist-tag target, or when it was published after the
+// cutoff, the highest older version below it (prereleases only for a
+// prerelease tag), as npm does for --before
+func (p *Packument) tagBefore(tag, target string) (string, error) {
+	if p.published(target) {
+		return target, nil
+	}
+	tv, err := semver.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("dist-tag %s (%s) was published after %s", tag, target, publishedBefore.Format(time.RFC3339))
+	}
+	for _, sv := range p.sorted {
+		if sv.Compare(tv) > 0 || !p.published(sv.Original()) {
+			continue
+		}
+		if len(sv.Prerelease) > 0 && len(tv.Prerelease) == 0 {
+			continue
+		}
+		return sv.Original(), nil
+	}
+	return "", fmt.Errorf("no version of dist-tag %s published before %s", tag, publishedBefore.Format(time.RFC3339))
+}
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,6 +18,44 @@ type cacheMeta struct {
 	ETag         string    `json:"etag"`
 	LastModified string    `json:"lastModified"`
 	CachedAt     time.Time `json:"cachedAt"`
+	// MaxAge is the registry's Cache-Control max-age; zero means defaultMaxAge
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+}
+
+// defaultMaxAge is used when the registry sent no max-age (npmjs sends 300s)
+const defaultMaxAge = 5 * time.Minute
+
+// fresh reports whether the cached packument may be used without revalidation
+func (m cacheMeta) fresh() bool {
+	if m.CachedAt.IsZero() {
+		return false
+	}
+	maxAge := m.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	return time.Since(m.CachedAt) < maxAge
+}
+
+// parseMaxAge extracts max-age from a Cache-Control header
+func parseMaxAge(header string) time.Duration {
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if v, ok := strings.CutPrefix(part, "max-age="); ok {
+			if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+				return time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+func readCachedPackument(dataPath string) (*Packument, error) {
+	b, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	return parsePackument(b)
 }
 
 func registryCacheDir() (string, error) {
@@ -32,13 +72,83 @@ func registryCacheDir() (string, error) {
 
 var httpSem = make(chan struct{}, 64)
 
-func getRegistryResponseCached(pkgName string) (*RegistryResponse, error) {
-	dir, err := registryCacheDir()
+const (
+	// abbreviatedAccept asks for the install-time document: versions with
+	// their dependencies, dist, engines, bin, os/cpu and deprecated, no time or readme
+	abbreviatedAccept = "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8, */*"
+	fullAccept        = "application/json"
+)
+
+// packument returns the document resolution needs: the abbreviated one,
+// unless a publish cutoff needs the time map only full documents carry.
+// Documents are loaded once per process and then served from memory.
+func (g *Registry) packument(pkgName string) (*Packument, error) {
+	// a full document can also serve abbreviated lookups
+	if p, ok := g.docs.Get("full:" + pkgName); ok {
+		return p, nil
+	}
+	full := !publishedBefore.IsZero()
+	key := "abbreviated:" + pkgName
+	if full {
+		key = "full:" + pkgName
+	}
+	return g.docs.Do(key, func() (*Packument, error) {
+		return g.cachedPackument(pkgName, full)
+	})
+}
+
+// writeFileAtomic writes through a temp file and a rename, so concurrent
+// writers and readers never see a partial cache file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// cachedPackument returns the full or abbreviated registry document of a
+// package, revalidating the on-disk copy according to the network mode. Full
+// documents live in ~/.npgo/registry-cache/<registry>, abbreviated ones in
+// its abbreviated/ subdirectory, each with its own ETag.
+func (g *Registry) cachedPackument(pkgName string, full bool) (*Packument, error) {
+	root, err := registryCacheDir()
 	if err != nil {
 		return nil, err
 	}
+	root = filepath.Join(root, g.cacheNamespace(pkgName))
+	dir, accept := root, fullAccept
+	if !full {
+		dir, accept = filepath.Join(root, "abbreviated"), abbreviatedAccept
+	}
 	dataPath := filepath.Join(dir, pkgName+".json")
 	metaPath := filepath.Join(dir, pkgName+".meta.json")
+	// when the registry can't be used, a full document is a superset that can
+	// stand in for a missing abbreviated one
+	readStale := func() (*Packument, error) {
+		rr, err := readCachedPackument(dataPath)
+		if err != nil && !full {
+			return readCachedPackument(filepath.Join(root, pkgName+".json"))
+		}
+		return rr, err
+	}
 	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
 		return nil, err
 	}
@@ -51,7 +161,22 @@ func getRegistryResponseCached(pkgName string) (*RegistryResponse, error) {
 		_ = json.Unmarshal(b, &meta)
 	}
 
-	url := fmt.Sprintf("https://registry.npmjs.org/%s", pkgName)
+	switch networkMode {
+	case Offline:
+		rr, err := readStale()
+		if err != nil {
+			return nil, offlineError("no cached metadata for %s in %s (run an online install first)", pkgName, root)
+		}
+		return rr, nil
+	case PreferOffline:
+		if meta.fresh() {
+			if rr, err := readCachedPackument(dataPath); err == nil {
+				return rr, nil
+			}
+		}
+	}
+
+	url := g.packumentURL(pkgName)
 	// bound request with timeout to avoid goroutine leaks
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -59,6 +184,8 @@ func getRegistryResponseCached(pkgName string) (*RegistryResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Accept", accept)
+	g.authorize(req)
 	if meta.ETag != "" {
 		req.Header.Set("If-None-Match", meta.ETag)
 	}
@@ -70,11 +197,8 @@ func getRegistryResponseCached(pkgName string) (*RegistryResponse, error) {
 	resp, err := HTTPClient.Do(req)
 	<-httpSem
 	if err != nil {
-		if b, err2 := os.ReadFile(dataPath); err2 == nil {
-			var rr RegistryResponse
-			if jsonErr := json.Unmarshal(b, &rr); jsonErr == nil {
-				return &rr, nil
-			}
+		if rr, err2 := readStale(); err2 == nil {
+			return rr, nil
 		}
 		return nil, err
 	}
@@ -82,40 +206,36 @@ func getRegistryResponseCached(pkgName string) (*RegistryResponse, error) {
 
 	switch resp.StatusCode {
 	case http.StatusNotModified:
-		b, err := os.ReadFile(dataPath)
+		rr, err := readCachedPackument(dataPath)
 		if err != nil {
 			return nil, fmt.Errorf("cache miss after 304: %w", err)
 		}
-		var rr RegistryResponse
-		if err := json.Unmarshal(b, &rr); err != nil {
-			return nil, err
+		// a revalidated entry is fresh again
+		meta.CachedAt = time.Now()
+		meta.MaxAge = parseMaxAge(resp.Header.Get("Cache-Control"))
+		if mb, err := json.MarshalIndent(meta, "", "  "); err == nil {
+			_ = writeFileAtomic(metaPath, mb)
 		}
-		return &rr, nil
+		return rr, nil
 	case http.StatusOK:
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return nil, err
 		}
-		if err := os.WriteFile(dataPath, body, 0644); err != nil {
+		if err := writeFileAtomic(dataPath, body); err != nil {
 			return nil, err
 		}
 		meta.ETag = resp.Header.Get("ETag")
 		meta.LastModified = resp.Header.Get("Last-Modified")
 		meta.CachedAt = time.Now()
+		meta.MaxAge = parseMaxAge(resp.Header.Get("Cache-Control"))
 		if mb, err := json.MarshalIndent(meta, "", "  "); err == nil {
-			_ = os.WriteFile(metaPath, mb, 0644)
+			_ = writeFileAtomic(metaPath, mb)
 		}
-		var rr RegistryResponse
-		if err := json.Unmarshal(body, &rr); err != nil {
-			return nil, err
-		}
-		return &rr, nil
+		return parsePackument(body)
 	default:
-		if b, err2 := os.ReadFile(dataPath); err2 == nil {
-			var rr RegistryResponse
-			if jsonErr := json.Unmarshal(b, &rr); jsonErr == nil {
-				return &rr, nil
-			}
+		if rr, err2 := readStale(); err2 == nil {
+			return rr, nil
 		}
 		return nil, fmt.Errorf("registry status %d", resp.StatusCode)
 	}
@@ -137,7 +257,7 @@ func PrefetchRegistry(pkgs []string, concurrency int) {
 		go func() {
 			defer wg.Done()
 			defer func() { <-sem }()
-			_, _ = getRegistryResponseCached(n)
+			_, _ = Default.packument(n)
 		}()
 	}
 	wg.Wait()
@@ -1,12 +1,14 @@
 package registry
 
 import (
-	"encoding/json"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -29,158 +31,142 @@ type PackageMetadata struct {
 	Version    string `json:"version"`
 	TarballURL string `json:"dist.tarball"`
 	Dist       struct {
-		Tarball string `json:"tarball"`
+		Tarball   string `json:"tarball"`
+		Integrity string `json:"integrity,omitempty"`
+		Shasum    string `json:"shasum,omitempty"`
 	} `json:"dist"`
 	Dependencies         map[string]string `json:"dependencies"`
 	OptionalDependencies map[string]string `json:"optionalDependencies"`
 	PeerDependencies     map[string]string `json:"peerDependencies"`
+	PeerDependenciesMeta map[string]struct {
+		Optional bool `json:"optional"`
+	} `json:"peerDependenciesMeta,omitempty"`
+	OS   []string `json:"os,omitempty"`
+	CPU  []string `json:"cpu,omitempty"`
+	Libc []string `json:"libc,omitempty"`
+	// Engines is usually an object; a few old packages publish an array
+	Engines          interface{}       `json:"engines,omitempty"`
+	Bin              interface{}       `json:"bin,omitempty"`
+	Scripts          map[string]string `json:"scripts,omitempty"`
+	HasInstallScript bool              `json:"hasInstallScript,omitempty"`
+	// Deprecated is the deprecation message; a few packuments publish false
+	Deprecated interface{} `json:"deprecated,omitempty"`
 }
 
-type RegistryResponse struct {
-	Name     string                 `json:"name"`
-	Versions map[string]interface{} `json:"versions"`
-	DistTags struct {
-		Latest string `json:"latest"`
-	} `json:"dist-tags"`
-}
-
-func FetchMetadata(pkgName, version string) (*PackageMetadata, error) {
-	// Use cached registry document with ETag/Last-Modified support
-	registryResp, err := getRegistryResponseCached(pkgName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch registry data: %w", err)
+// SRI returns the dist integrity, deriving a sha1 SRI from the legacy shasum
+func (m *PackageMetadata) SRI() string {
+	if m.Dist.Integrity != "" {
+		return m.Dist.Integrity
 	}
-
-	targetVersion := version
-	if version == "latest" {
-		targetVersion = registryResp.DistTags.Latest
+	if raw, err := hex.DecodeString(m.Dist.Shasum); err == nil && len(raw) > 0 {
+		return "sha1-" + base64.StdEncoding.EncodeToString(raw)
 	}
+	return ""
+}
 
-	versionData, exists := registryResp.Versions[targetVersion]
-	if !exists {
-		if resolved := resolveVersionFromMap(registryResp.Versions, targetVersion); resolved != "" {
-			targetVersion = resolved
-			versionData = registryResp.Versions[targetVersion]
-		} else {
-			return nil, fmt.Errorf("version %s not found for package %s", targetVersion, pkgName)
+// EngineRanges returns the engines field as name → range
+func (m *PackageMetadata) EngineRanges() map[string]string {
+	obj, ok := m.Engines.(map[string]interface{})
+	if !ok || len(obj) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(obj))
+	for k, v := range obj {
+		if s, ok := v.(string); ok {
+			out[k] = s
 		}
 	}
+	return out
+}
 
-	versionJSON, err := json.Marshal(versionData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal version data: %w", err)
+// BinMap normalizes bin to command → path; a string bin is named after the
+// package (without its scope)
+func (m *PackageMetadata) BinMap() map[string]string {
+	switch v := m.Bin.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		name := m.Name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		return map[string]string{name: v}
+	case map[string]interface{}:
+		out := make(map[string]string, len(v))
+		for k, p := range v {
+			if s, ok := p.(string); ok && s != "" {
+				out[k] = s
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
 	}
+	return nil
+}
 
-	var metadata PackageMetadata
-	if err := json.Unmarshal(versionJSON, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to parse version metadata: %w", err)
+// DeprecationMessage returns the deprecation message, or "" when the version
+// is not deprecated
+func (m *PackageMetadata) DeprecationMessage() string {
+	if s, ok := m.Deprecated.(string); ok {
+		return strings.TrimSpace(s)
 	}
+	return ""
+}
 
-	if metadata.TarballURL == "" {
-		metadata.TarballURL = metadata.Dist.Tarball
+// InstallScript reports whether the package runs preinstall/install/postinstall
+func (m *PackageMetadata) InstallScript() bool {
+	if m.HasInstallScript {
+		return true
 	}
-
-	if metadata.TarballURL == "" {
-		return nil, fmt.Errorf("no tarball URL found for package %s@%s", pkgName, targetVersion)
+	for _, s := range []string{"preinstall", "install", "postinstall"} {
+		if m.Scripts[s] != "" {
+			return true
+		}
 	}
+	return false
+}
 
-	return &metadata, nil
+// FetchMetadata resolves a version spec against the default registry
+func FetchMetadata(pkgName, version string) (*PackageMetadata, error) {
+	return Default.fetchMetadata(pkgName, version)
 }
 
-func resolveVersionFromMap(versions map[string]interface{}, spec string) string {
-	s := spec
-	if len(s) > 2 && (s[len(s)-2:] == ".x" || s[len(s)-2:] == ".*") {
-		s = s[:len(s)-2]
-	}
-	parts := make([]int, 0, 3)
-	segs := 0
-	cur := 0
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		if c == '.' {
-			parts = append(parts, cur)
-			cur = 0
-			segs++
-			continue
-		}
-		if c < '0' || c > '9' {
-			return ""
-		}
-		cur = cur*10 + int(c-'0')
-	}
-	if len(s) > 0 {
-		parts = append(parts, cur)
-		segs++
-	}
-	if segs == 0 || segs > 2 {
-		return ""
+func (g *Registry) fetchMetadata(pkgName, version string) (*PackageMetadata, error) {
+	// Use cached registry document with ETag/Last-Modified support
+	doc, err := g.packument(pkgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch registry data: %w", err)
 	}
+	return doc.manifest(pkgName, version)
+}
 
-	best := ""
-	bestMaj, bestMin, bestPatch := -1, -1, -1
-	for v := range versions {
-		maj, min, pat := parseSemver(v)
-		if maj < 0 {
-			continue
-		}
-		if segs == 1 {
-			if maj != parts[0] {
-				continue
-			}
-		} else if segs == 2 {
-			if maj != parts[0] || min != parts[1] {
-				continue
-			}
-		}
-		if maj > bestMaj || (maj == bestMaj && (min > bestMin || (min == bestMin && pat > bestPatch))) {
-			bestMaj, bestMin, bestPatch = maj, min, pat
-			best = v
-		}
-	}
-	return best
+// DistTags returns the dist-tags of a package from the default registry
+func DistTags(pkgName string) (map[string]string, error) {
+	return Default.DistTags(pkgName)
 }
 
-func parseSemver(v string) (int, int, int) {
-	n1, n2, n3 := -1, -1, -1
-	cur := 0
-	seg := 0
-	for i := 0; i <= len(v); i++ {
-		if i == len(v) || v[i] == '.' {
-			if seg == 0 {
-				n1 = cur
-			} else if seg == 1 {
-				n2 = cur
-			} else if seg == 2 {
-				n3 = cur
-			}
-			seg++
-			cur = 0
-			continue
-		}
-		c := v[i]
-		if c < '0' || c > '9' {
-			return -1, -1, -1
-		}
-		cur = cur*10 + int(c-'0')
-	}
-	if n1 < 0 {
-		return -1, -1, -1
-	}
-	if n2 < 0 {
-		n2 = 0
-	}
-	if n3 < 0 {
-		n3 = 0
+// DistTags returns the dist-tags of a package from the cached packument
+func (g *Registry) DistTags(pkgName string) (map[string]string, error) {
+	doc, err := g.packument(pkgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch registry data: %w", err)
 	}
-	return n1, n2, n3
+	return doc.DistTags, nil
 }
 
 // DownloadTarball downloads the package tarball to cache directory
 func DownloadTarball(tarballURL, pkgName, version string) (string, error) {
+	if networkMode == Offline {
+		return "", offlineError("%s@%s is not in the store", pkgName, version)
+	}
 	req, err := http.NewRequest(http.MethodGet, tarballURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
+	Default.authorize(req)
 
 	resp, err := HTTPClient.Do(req)
 	if err != nil {
@@ -214,11 +200,22 @@ func DownloadTarball(tarballURL, pkgName, version string) (string, error) {
 	return filepath, nil
 }
 
+// StreamTarball opens a tarball download with the default registry's
+// credentials; in Offline mode it always fails, callers are expected to have
+// checked the store first.
 func StreamTarball(tarballURL string) (io.ReadCloser, error) {
+	return Default.stream(tarballURL)
+}
+
+func (g *Registry) stream(tarballURL string) (io.ReadCloser, error) {
+	if networkMode == Offline {
+		return nil, offlineError("%s is not in the store", tarballURL)
+	}
 	req, err := http.NewRequest(http.MethodGet, tarballURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	g.authorize(req)
 
 	resp, err := HTTPClient.Do(req)
 	if err != nil {
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"npgo/internal/semver"
+)
+
+// Memory is an in-memory MetadataSource and TarballSource, for tests and for
+// serving packages from a store other than an npm registry
+type Memory struct {
+	mu         sync.RWMutex
+	packuments map[string]*Packument
+	tarballs   map[string][]byte
+	// urls maps dist.tarball URLs to tarballs keys, for URL dependency specs
+	urls map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{packuments: make(map[string]*Packument), tarballs: make(map[string][]byte), urls: make(map[string]string)}
+}
+
+// Add publishes a version and its tarball. dist-tags.latest follows the
+// highest stable version added.
+func (m *Memory) Add(md PackageMetadata, tarball []byte) error {
+	if md.Name == "" || !semver.Valid(md.Version) {
+		return fmt.Errorf("invalid package %s@%s", md.Name, md.Version)
+	}
+	if md.Dist.Tarball == "" {
+		md.Dist.Tarball = fmt.Sprintf("memory://%s/-/%s.tgz", md.Name, md.Version)
+	}
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc := m.packuments[md.Name]
+	if doc == nil {
+		doc = &Packument{Name: md.Name, Versions: make(map[string]json.RawMessage), DistTags: make(map[string]string)}
+		m.packuments[md.Name] = doc
+	}
+	doc.Versions[md.Version] = data
+	doc.index()
+	if v, _ := semver.Parse(md.Version); len(v.Prerelease) == 0 {
+		if latest := doc.DistTags["latest"]; latest == "" || semver.Compare(md.Version, latest) > 0 {
+			doc.DistTags["latest"] = md.Version
+		}
+	}
+	m.tarballs[md.Name+"@"+md.Version] = tarball
+	m.urls[md.Dist.Tarball] = md.Name + "@" + md.Version
+	return nil
+}
+
+// Tag points a dist-tag at a version
+func (m *Memory) Tag(name, tag, version string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if doc := m.packuments[name]; doc != nil {
+		doc.DistTags[tag] = version
+	}
+}
+
+func (m *Memory) Metadata(name, spec string) (*PackageMetadata, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	doc, ok := m.packuments[name]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", name)
+	}
+	return doc.manifest(name, spec)
+}
+
+func (m *Memory) DistTags(name string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	doc, ok := m.packuments[name]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", name)
+	}
+	tags := make(map[string]string, len(doc.DistTags))
+	for k, v := range doc.DistTags {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// Tarball serves name@version, or the tarball published at url when the
+// version is not known (tarball URL dependencies)
+func (m *Memory) Tarball(name, version, url string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.tarballs[name+"@"+version]
+	if !ok && url != "" {
+		data, ok = m.tarballs[m.urls[url]]
+	}
+	if !ok {
+		return nil, fmt.Errorf("no tarball for %s@%s", name, version)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
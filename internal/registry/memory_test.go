@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	mem := NewMemory()
+	for _, v := range []string{"1.0.0", "1.2.0", "2.0.0-rc.1"} {
+		if err := mem.Add(PackageMetadata{Name: "a", Version: v}, []byte("a@"+v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	url := PackageMetadata{Name: "b", Version: "1.0.0"}
+	url.Dist.Tarball = "https://example.invalid/b-1.0.0.tgz"
+	if err := mem.Add(url, []byte("b@1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Add(PackageMetadata{Name: "c", Version: "one"}, nil); err == nil {
+		t.Error("Add accepted an invalid version")
+	}
+	mem.Tag("a", "next", "2.0.0-rc.1")
+
+	tags, err := mem.DistTags("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["latest"] != "1.2.0" || tags["next"] != "2.0.0-rc.1" {
+		t.Errorf("DistTags = %v", tags)
+	}
+
+	metadata := []struct {
+		name, spec, want string
+	}{
+		{"a", "^1", "1.2.0"},
+		{"a", "1.0.0", "1.0.0"},
+		{"a", "", "1.2.0"},
+		{"a", "next", "2.0.0-rc.1"},
+		{"a", "^3", ""},
+		{"missing", "*", ""},
+	}
+	for _, tt := range metadata {
+		md, err := mem.Metadata(tt.name, tt.spec)
+		got := ""
+		if err == nil {
+			got = md.Version
+		}
+		if got != tt.want {
+			t.Errorf("Metadata(%q, %q) = %q (%v), want %q", tt.name, tt.spec, got, err, tt.want)
+		}
+	}
+
+	tarballs := []struct {
+		name, version, url, want string
+	}{
+		{"a", "1.2.0", "", "a@1.2.0"},
+		{"b", "", "https://example.invalid/b-1.0.0.tgz", "b@1.0.0"},
+		{"b", "", "https://example.invalid/other.tgz", ""},
+		{"a", "9.9.9", "", ""},
+	}
+	for _, tt := range tarballs {
+		rc, err := mem.Tarball(tt.name, tt.version, tt.url)
+		got := ""
+		if err == nil {
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			got = string(data)
+		}
+		if got != tt.want {
+			t.Errorf("Tarball(%q, %q, %q) = %q (%v), want %q", tt.name, tt.version, tt.url, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NetworkMode controls when the registry is contacted
+type NetworkMode int
+
+const (
+	// Online revalidates every cached packument with a conditional GET
+	Online NetworkMode = iota
+	// PreferOffline serves cached packuments that are still fresh without a request
+	PreferOffline
+	// Offline never touches the network; cache misses are errors
+	Offline
+)
+
+// ErrOffline is returned for anything that would need the network in Offline mode
+var ErrOffline = errors.New("offline")
+
+var networkMode = Online
+
+// SetNetworkMode switches the mode for all later registry calls. It is meant
+// to be called once, before resolution starts.
+func SetNetworkMode(m NetworkMode) {
+	networkMode = m
+}
+
+// Mode returns the current network mode
+func Mode() NetworkMode {
+	return networkMode
+}
+
+func (m NetworkMode) String() string {
+	switch m {
+	case PreferOffline:
+		return "prefer-offline"
+	case Offline:
+		return "offline"
+	}
+	return "online"
+}
+
+// offlineError reports a resource that is not available without the network
+func offlineError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrOffline, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"npgo/internal/semver"
+)
+
+// Packument is a package's registry document. Version keys are parsed when
+// the document is loaded; each version's manifest stays raw JSON until a
+// spec selects it, and is then decoded once.
+type Packument struct {
+	Name     string            `json:"name"`
+	DistTags map[string]string `json:"dist-tags"`
+	// Time maps versions (plus "created"/"modified") to publish timestamps;
+	// only full documents carry it
+	Time     map[string]string          `json:"time,omitempty"`
+	Versions map[string]json.RawMessage `json:"versions"`
+
+	// sorted holds the parseable version keys, highest first
+	sorted    []*semver.Version
+	mu        sync.Mutex
+	manifests map[string]*PackageMetadata
+}
+
+// parsePackument decodes a registry document and indexes its versions
+func parsePackument(data []byte) (*Packument, error) {
+	var p Packument
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	p.index()
+	return &p, nil
+}
+
+// index parses the version keys and drops decoded manifests; it must run
+// before the packument is shared
+func (p *Packument) index() {
+	p.sorted = p.sorted[:0]
+	for v := range p.Versions {
+		if sv, err := semver.Parse(v); err == nil {
+			p.sorted = append(p.sorted, sv)
+		}
+	}
+	sort.Slice(p.sorted, func(i, j int) bool { return p.sorted[i].Compare(p.sorted[j]) > 0 })
+	p.manifests = make(map[string]*PackageMetadata)
+}
+
+// manifest picks the version a spec selects (exact, dist-tag or range) and
+// decodes its metadata
+func (p *Packument) manifest(pkgName, spec string) (*PackageMetadata, error) {
+	target := spec
+	if target == "" {
+		target = "latest"
+	}
+	if _, exact := p.Versions[target]; !exact {
+		if tagged, ok := p.DistTags[target]; ok {
+			v, err := p.tagBefore(target, tagged)
+			if err != nil {
+				return nil, fmt.Errorf("%w for package %s", err, pkgName)
+			}
+			target = v
+		}
+	}
+	if _, exact := p.Versions[target]; !exact {
+		v, err := p.maxSatisfying(target)
+		if err != nil {
+			return nil, fmt.Errorf("%w for package %s", err, pkgName)
+		}
+		target = v
+	}
+	return p.decode(pkgName, target)
+}
+
+// decode returns the manifest of one version, decoding it on first use.
+// Manifests are shared and must not be modified.
+func (p *Packument) decode(pkgName, version string) (*PackageMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if md, ok := p.manifests[version]; ok {
+		return md, nil
+	}
+	raw, ok := p.Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("no version %s for package %s", version, pkgName)
+	}
+	var md PackageMetadata
+	if err := json.Unmarshal(raw, &md); err != nil {
+		return nil, fmt.Errorf("failed to parse version metadata: %w", err)
+	}
+	if md.TarballURL == "" {
+		md.TarballURL = md.Dist.Tarball
+	}
+	if md.TarballURL == "" {
+		return nil, fmt.Errorf("no tarball URL found for package %s@%s", pkgName, version)
+	}
+	p.manifests[version] = &md
+	return &md, nil
+}
+
+// maxSatisfying picks the version for a range the way npm does:
+// dist-tags.latest when it satisfies, otherwise the highest match. Versions
+// published after the --before cutoff are not candidates.
+func (p *Packument) maxSatisfying(spec string) (string, error) {
+	rng, err := semver.ParseRange(spec)
+	if err != nil {
+		return "", err
+	}
+	latest := p.DistTags["latest"]
+	if lv, err := semver.Parse(latest); err == nil && rng.Satisfies(lv) && p.published(latest) {
+		return latest, nil
+	}
+	for _, v := range p.sorted {
+		if rng.Satisfies(v) && p.published(v.Original()) {
+			return v.Original(), nil
+		}
+	}
+	if !publishedBefore.IsZero() {
+		return "", fmt.Errorf("no version matching %s published before %s", spec, publishedBefore.Format(time.RFC3339))
+	}
+	return "", fmt.Errorf("no version matching %s", spec)
+}
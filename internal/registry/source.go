@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"io"
+	"strings"
+
+	"npgo/internal/memo"
+)
+
+// MetadataSource serves version metadata to the resolver. The spec is an
+// exact version, a range or a dist-tag; "" means latest.
+type MetadataSource interface {
+	Metadata(name, spec string) (*PackageMetadata, error)
+	DistTags(name string) (map[string]string, error)
+}
+
+// TarballSource opens the tarball of a resolved package for the installer
+type TarballSource interface {
+	Tarball(name, version, url string) (io.ReadCloser, error)
+}
+
+// DefaultURL is the public npm registry
+const DefaultURL = "https://registry.npmjs.org"
+
+// Registry is an HTTP npm registry. Packuments go through the on-disk
+// registry-cache (ETag revalidation, offline modes); tarballs are streamed.
+type Registry struct {
+	URL string
+	// Scopes maps "@scope" to the registry its packages come from
+	Scopes map[string]string
+	// auth maps "//host/path/" prefixes to an Authorization header value
+	auth map[string]string
+
+	// docs keeps decoded packuments for the life of the process, keyed by
+	// "full:" or "abbreviated:" plus the name; concurrent loads are coalesced
+	docs memo.Map[*Packument]
+}
+
+// Default is the registry used by the package-level helpers and by resolvers
+// and installers that were not given another source
+var Default = NewRegistry(DefaultURL)
+
+func NewRegistry(url string) *Registry {
+	return &Registry{URL: strings.TrimSuffix(url, "/")}
+}
+
+// Metadata resolves a spec against the packument, which is revalidated
+// through the registry-cache and decoded once per process; fields that can
+// change after publishing, such as deprecated, are therefore always current.
+func (g *Registry) Metadata(name, spec string) (*PackageMetadata, error) {
+	return g.fetchMetadata(name, spec)
+}
+
+// Tarball streams a package tarball from its dist URL
+func (g *Registry) Tarball(name, version, url string) (io.ReadCloser, error) {
+	return g.stream(url)
+}
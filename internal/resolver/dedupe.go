@@ -0,0 +1,162 @@
+package resolver
+
+import (
+	"sort"
+
+	"npgo/internal/semver"
+)
+
+// Dedupe collapses duplicate versions within a graph loaded by LoadLockfile,
+// without registry requests: edges only move onto versions already locked.
+// It returns the graph reachable from root afterwards.
+func (r *Resolver) Dedupe(root map[string]string) map[string]*Dependency {
+	r.markScopedUse(root)
+	graph := r.dedupe(root)
+	r.linkEdges(graph)
+	return graph
+}
+
+// markScopedUse recomputes, for a graph loaded from a lockfile, the edges a
+// partial override match could apply to (BuildGraph records them as it goes)
+func (r *Resolver) markScopedUse(root map[string]string) {
+	r.scopedUse = make(map[string]bool)
+	if len(r.overrides) == 0 {
+		return
+	}
+	type visit struct {
+		dep *Dependency
+		in  overrideState
+	}
+	var stack []visit
+	for n, s := range root {
+		if d := r.Lookup(n, s); d != nil {
+			stack = append(stack, visit{dep: d})
+		}
+	}
+	seen := make(map[string]bool)
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		key := nodeKey(v.dep) + "\x00" + v.in.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out := r.advance(v.in, v.dep)
+		for cn, cs := range v.dep.RawDeps {
+			c := r.child(v.dep, cn)
+			if c == nil {
+				continue
+			}
+			if r.selects(out, c) {
+				r.scopedUse[cn+"@"+cs] = true
+			}
+			stack = append(stack, visit{dep: c, in: out})
+		}
+	}
+}
+
+// dedupe collapses compatible ranges onto shared versions. For every package
+// with more than one resolved version it greedily picks the version that
+// satisfies the most name@range edges (highest first on ties) until every
+// edge is covered, then drops versions nothing depends on anymore.
+func (r *Resolver) dedupe(root map[string]string) map[string]*Dependency {
+	type edge struct {
+		key string
+		rng *semver.Range
+	}
+	versions := make(map[string][]*Dependency)
+	edges := make(map[string][]edge)
+	r.cache.Range(func(key string, d *Dependency) bool {
+		// edges inside an override scope keep their resolution, since
+		// another version could need a different scoped subtree
+		if r.skipped[key] || r.scopedUse[key] || d.LocalPath != "" {
+			return true
+		}
+		rng, err := semver.ParseRange(key[len(d.Name)+1:])
+		if err != nil {
+			// dist-tags and other non-range specs keep their own resolution
+			return true
+		}
+		edges[d.Name] = append(edges[d.Name], edge{key: key, rng: rng})
+		known := false
+		for _, v := range versions[d.Name] {
+			if v.Resolved == d.Resolved {
+				known = true
+				break
+			}
+		}
+		if !known {
+			versions[d.Name] = append(versions[d.Name], d)
+		}
+		return true
+	})
+
+	for name, cands := range versions {
+		if len(cands) < 2 {
+			continue
+		}
+		sort.Slice(cands, func(i, j int) bool { return semver.Compare(cands[i].Resolved, cands[j].Resolved) > 0 })
+		parsed := make([]*semver.Version, len(cands))
+		for i, c := range cands {
+			parsed[i], _ = semver.Parse(c.Resolved)
+		}
+		pending := edges[name]
+		for len(pending) > 0 {
+			best, bestCount := -1, 0
+			for i := range cands {
+				if parsed[i] == nil {
+					continue
+				}
+				count := 0
+				for _, e := range pending {
+					if e.rng.Satisfies(parsed[i]) {
+						count++
+					}
+				}
+				if count > bestCount {
+					best, bestCount = i, count
+				}
+			}
+			if best < 0 {
+				break
+			}
+			rest := pending[:0]
+			for _, e := range pending {
+				if e.rng.Satisfies(parsed[best]) {
+					r.cache.Store(e.key, cands[best])
+				} else {
+					rest = append(rest, e)
+				}
+			}
+			pending = rest
+		}
+	}
+	return r.reachable(root)
+}
+
+// reachable walks the resolved edges from the root specs
+func (r *Resolver) reachable(root map[string]string) map[string]*Dependency {
+	graph := make(map[string]*Dependency)
+	var stack []*Dependency
+	for n, s := range root {
+		if d := r.Lookup(n, s); d != nil {
+			stack = append(stack, d)
+		}
+	}
+	for len(stack) > 0 {
+		d := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		key := nodeKey(d)
+		if _, ok := graph[key]; ok {
+			continue
+		}
+		graph[key] = d
+		for cn := range d.RawDeps {
+			if c := r.child(d, cn); c != nil {
+				stack = append(stack, c)
+			}
+		}
+	}
+	return graph
+}
@@ -0,0 +1,90 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Deprecation is a deprecated package in the resolved tree
+type Deprecation struct {
+	Dep     *Dependency
+	Message string
+	// Path is the shortest chain of dependents that pulls the package in,
+	// from the root project down to the package itself
+	Path []string
+	// Direct is set when the root project or a workspace member depends on
+	// the package itself
+	Direct bool
+}
+
+func (d Deprecation) String() string {
+	return fmt.Sprintf("%s@%s (%s): %s", d.Dep.Name, d.Dep.Resolved, strings.Join(d.Path, " > "), d.Message)
+}
+
+// Deprecations lists the deprecated packages reachable from the root specs,
+// sorted by name and version
+func (r *Resolver) Deprecations(root map[string]string) []Deprecation {
+	type item struct {
+		dep  *Dependency
+		path []string
+	}
+	start := r.dependencyPath(nil)
+	names := make([]string, 0, len(root))
+	for n := range root {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// different specs can resolve to separate nodes of the same version
+	key := func(d *Dependency) string { return d.Name + "@" + d.Resolved }
+	direct := make(map[string]bool)
+	seen := make(map[string]bool)
+	var queue []item
+	for _, n := range names {
+		d := r.Lookup(n, root[n])
+		if d == nil || seen[key(d)] {
+			continue
+		}
+		seen[key(d)] = true
+		direct[key(d)] = true
+		queue = append(queue, item{d, append(append([]string{}, start...), d.Name)})
+	}
+
+	var out []Deprecation
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+		member := r.workspaces[it.dep.Name] != nil && it.dep.Linked
+		if it.dep.Deprecated != "" {
+			out = append(out, Deprecation{Dep: it.dep, Message: it.dep.Deprecated, Path: it.path})
+		}
+		children := make([]string, 0, len(it.dep.Dependencies))
+		for n := range it.dep.Dependencies {
+			children = append(children, n)
+		}
+		sort.Strings(children)
+		for _, n := range children {
+			c := it.dep.Dependencies[n]
+			if member {
+				direct[key(c)] = true
+			}
+			if seen[key(c)] {
+				continue
+			}
+			seen[key(c)] = true
+			queue = append(queue, item{c, append(append([]string{}, it.path...), c.Name)})
+		}
+	}
+	// members are dequeued after their siblings, so directness is settled last
+	for i := range out {
+		out[i].Direct = direct[key(out[i].Dep)]
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Dep.Name != out[j].Dep.Name {
+			return out[i].Dep.Name < out[j].Dep.Name
+		}
+		return out[i].Dep.Resolved < out[j].Dep.Resolved
+	})
+	return out
+}
@@ -0,0 +1,57 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResolveError is a dependency edge that could not be resolved, together
+// with the chain of dependents that requested it
+type ResolveError struct {
+	Path []string // dependents from the root down, e.g. [app webpack terser]
+	Name string
+	Spec string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	chain := append(append([]string{}, e.Path...), e.Name+"@"+e.Spec)
+	return fmt.Sprintf("%s: %v", strings.Join(chain, " > "), e.Err)
+}
+
+func (e *ResolveError) Unwrap() error { return e.Err }
+
+// ResolveErrors returns the required edges the last BuildGraph failed to resolve
+func (r *Resolver) ResolveErrors() []*ResolveError {
+	return r.failures
+}
+
+// SetRootName names the project in the dependency paths of resolution errors
+func (r *Resolver) SetRootName(name string) {
+	r.rootName = name
+}
+
+// dependencyPath renders the chain of dependents leading to an edge
+func (r *Resolver) dependencyPath(chain []*Dependency) []string {
+	path := make([]string, 0, len(chain)+1)
+	if r.rootName != "" {
+		path = append(path, r.rootName)
+	}
+	for _, d := range chain {
+		path = append(path, d.Name)
+	}
+	return path
+}
+
+func resolutionError(failures []*ResolveError) error {
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Error() < failures[j].Error() })
+	if len(failures) == 1 {
+		return fmt.Errorf("failed to resolve %w", failures[0])
+	}
+	lines := make([]string, len(failures))
+	for i, f := range failures {
+		lines[i] = f.Error()
+	}
+	return fmt.Errorf("failed to resolve %d dependencies:\n  %s", len(failures), strings.Join(lines, "\n  "))
+}
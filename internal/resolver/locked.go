@@ -0,0 +1,268 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"npgo/internal/cas"
+	"npgo/internal/lockfile"
+	"npgo/internal/platform"
+	"npgo/internal/semver"
+)
+
+// ErrLockfileStale means package.json asks for something the lockfile does not record
+var ErrLockfileStale = errors.New("lockfile is out of date")
+
+// LoadLockfile rebuilds the graph recorded in a lockfile without any registry
+// metadata requests, so Lookup and PlanLayout work as after BuildGraph. root
+// holds the project's direct specs; workspace members are checked against
+// their own importers. The error wraps ErrLockfileStale when an importer is
+// no longer satisfied by the locked versions.
+func (r *Resolver) LoadLockfile(lf *lockfile.LockFile, root map[string]string, projectDir string) (map[string]*Dependency, error) {
+	r.skipped = make(map[string]bool)
+	r.warnings = nil
+	r.failures = nil
+
+	nodes := make(map[string]*Dependency, len(lf.Packages))
+	for _, e := range lf.Packages {
+		dep := &Dependency{
+			Name:         e.Name,
+			Spec:         e.Version,
+			Resolved:     e.Version,
+			TarballURL:   e.Resolved,
+			Dependencies: make(map[string]*Dependency),
+			RawDeps:      make(map[string]string, len(e.Dependencies)),
+			declared:     make(map[string]string, len(e.Dependencies)),
+			OptionalDeps: make(map[string]bool),
+			Override:     e.Override,
+			Scope:        e.Scope,
+			Source:       e.Source,
+			OS:           e.OS,
+			CPU:          e.CPU,
+			Libc:         e.Libc,
+
+			Integrity:        e.Integrity,
+			StoreHash:        e.StoreHash,
+			Engines:          e.Engines,
+			Bin:              e.Bin,
+			HasInstallScript: e.HasInstallScript,
+			Deprecated:       e.Deprecated,
+		}
+		for cn, edge := range e.Dependencies {
+			dep.declared[cn] = edge.Spec
+			dep.RawDeps[cn] = edgeSpec(edge)
+			if edge.Optional {
+				dep.OptionalDeps[cn] = true
+			}
+		}
+		nodes[nodeKey(dep)] = dep
+	}
+
+	// only publish into r.cache once the whole lockfile checks out, so a stale
+	// lockfile leaves the resolver clean for BuildGraph
+	cache := make(map[string]*Dependency)
+	stale := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrLockfileStale, fmt.Sprintf(format, args...))
+	}
+	if drift := r.LockfileDrift(lf, root); len(drift) > 0 {
+		return nil, stale("%s", drift[0])
+	}
+
+	roots := r.WithWorkspaces(root)
+	for name, spec := range roots {
+		var node *Dependency
+		if edge, ok := lf.Importers["."].Dependencies[name]; ok {
+			node = nodes[name+"@"+edge.Version]
+		} else if m := r.workspaces[name]; m != nil {
+			for _, d := range nodes {
+				if d.Name == name && d.Source == "workspace:"+m.RelDir {
+					node = d
+				}
+			}
+		}
+		if node == nil {
+			return nil, stale("no entry for %s@%s", name, spec)
+		}
+		cache[name+"@"+spec] = node
+	}
+	for _, e := range lf.Packages {
+		parent := nodes[nodeKey(&Dependency{Name: e.Name, Resolved: e.Version, Scope: e.Scope})]
+		for cn, edge := range e.Dependencies {
+			child := nodes[nodeKey(&Dependency{Name: cn, Resolved: edge.Version, Scope: edge.Scope})]
+			if child == nil {
+				return nil, stale("no entry for %s@%s (needed by %s@%s)", cn, edge.Version, e.Name, e.Version)
+			}
+			// the lockfile may come from another platform
+			skip := edge.Optional && !platform.Matches(child.OS, child.CPU, child.Libc)
+			if edge.Scope != "" {
+				if parent.variants == nil {
+					parent.variants = make(map[string]*Dependency)
+				}
+				if skip {
+					child = nil
+				}
+				parent.variants[cn] = child
+				continue
+			}
+			if skip {
+				r.skipped[cn+"@"+edgeSpec(edge)] = true
+				continue
+			}
+			cache[cn+"@"+edgeSpec(edge)] = child
+		}
+	}
+
+	for _, d := range nodes {
+		if err := r.restoreSource(d, projectDir); err != nil {
+			return nil, err
+		}
+	}
+	for key, d := range cache {
+		r.cache.Store(key, d)
+	}
+	graph := r.reachable(roots)
+	r.linkEdges(graph)
+	return graph, nil
+}
+
+// edgeSpec is the spec a locked edge was resolved with
+func edgeSpec(e lockfile.Edge) string {
+	if e.Override != "" {
+		return e.Override
+	}
+	return e.Spec
+}
+
+// Drift is one difference between a package.json and its lockfile importer
+type Drift struct {
+	Importer string // "." or a workspace directory
+	Name     string // dependency name; "" when the whole importer differs
+	Want     string // spec in package.json; "" when the dependency was removed
+	Locked   string // spec in the lockfile; "" when it is not locked
+	Version  string // locked version
+	// NewImporter marks a workspace with no importer in the lockfile yet
+	NewImporter bool
+	// Overrides marks overrides/resolutions that changed since locking
+	Overrides bool
+}
+
+func (d Drift) String() string {
+	where := d.Importer
+	if where != "." {
+		where = "workspace " + where
+	}
+	switch {
+	case d.Overrides:
+		return "overrides/resolutions changed since the lockfile was written"
+	case d.NewImporter:
+		return fmt.Sprintf("%s: not in the lockfile", where)
+	case d.Name == "":
+		return fmt.Sprintf("%s: removed from the workspaces but still locked", where)
+	case d.Locked == "":
+		return fmt.Sprintf("%s: + %s@%s (not locked)", where, d.Name, d.Want)
+	case d.Want == "":
+		return fmt.Sprintf("%s: - %s@%s (locked %s, no longer in package.json)", where, d.Name, d.Locked, d.Version)
+	}
+	return fmt.Sprintf("%s: ~ %s %s → %s (locked %s does not satisfy)", where, d.Name, d.Locked, d.Want, d.Version)
+}
+
+// LockfileDrift compares the direct specs of the root and of every workspace
+// member with the lockfile importers, and the override rules with the ones
+// the lockfile was resolved with. A spec that changed but is still satisfied
+// by the locked version is not drift.
+func (r *Resolver) LockfileDrift(lf *lockfile.LockFile, root map[string]string) []Drift {
+	importers := map[string]map[string]string{".": root}
+	for _, m := range r.workspaces {
+		importers[m.RelDir] = MemberSpecs(m)
+	}
+	var out []Drift
+	if lf.OverridesHash != r.OverridesHash() {
+		out = append(out, Drift{Importer: ".", Overrides: true})
+	}
+	for dir, specs := range importers {
+		imp, ok := lf.Importers[dir]
+		if !ok && dir != "." {
+			out = append(out, Drift{Importer: dir, NewImporter: true})
+			continue
+		}
+		for name, spec := range specs {
+			edge, ok := imp.Dependencies[name]
+			if !ok {
+				out = append(out, Drift{Importer: dir, Name: name, Want: spec})
+			} else if edge.Spec != spec && !semver.Satisfies(edge.Version, spec) {
+				out = append(out, Drift{Importer: dir, Name: name, Want: spec, Locked: edge.Spec, Version: edge.Version})
+			}
+		}
+		for name, edge := range imp.Dependencies {
+			if _, ok := specs[name]; !ok {
+				out = append(out, Drift{Importer: dir, Name: name, Locked: edge.Spec, Version: edge.Version})
+			}
+		}
+	}
+	for dir := range lf.Importers {
+		if _, ok := importers[dir]; !ok {
+			out = append(out, Drift{Importer: dir})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Importer != out[j].Importer {
+			return out[i].Importer < out[j].Importer
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// restoreSource points non-registry packages back at their content: linked
+// directories relative to the project, and a fresh CAS import for the rest.
+// A re-import must hash to the locked storeHash.
+func (r *Resolver) restoreSource(dep *Dependency, projectDir string) error {
+	switch proto := specProtocol(dep.Source); {
+	case dep.Source == "" || proto == protoAlias:
+		return nil
+	case proto == protoWorkspace || proto == protoLink:
+		rel := strings.TrimPrefix(strings.TrimPrefix(dep.Source, "workspace:"), "link:")
+		path := filepath.FromSlash(expandHome(rel))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(projectDir, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		dep.LocalPath = abs
+		dep.Linked = true
+		return nil
+	default:
+		if dep.StoreHash != "" {
+			if p, err := cas.PackagePath(dep.StoreHash); err == nil {
+				if _, err := os.Stat(filepath.Join(p, "package.json")); err == nil {
+					dep.LocalPath = p
+					return nil
+				}
+			}
+		}
+		// git pins a full SHA, so this does not re-resolve refs
+		d, err := r.resolveSource(dep.Name, dep.Source, proto)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s from %s: %w", dep.Name, dep.Source, err)
+		}
+		if dep.StoreHash != "" && d.StoreHash != dep.StoreHash {
+			// local files may be edited, which only means the lockfile is
+			// behind; anything fetched must come back byte for byte
+			cause := cas.ErrIntegrity
+			if proto == protoFile {
+				cause = ErrLockfileStale
+			}
+			return fmt.Errorf("%w: %s from %s is locked with storeHash %s but has %s",
+				cause, dep.Name, dep.Source, dep.StoreHash, d.StoreHash)
+		}
+		dep.LocalPath = d.LocalPath
+		dep.StoreHash = d.StoreHash
+		return nil
+	}
+}
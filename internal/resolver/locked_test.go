@@ -0,0 +1,85 @@
+package resolver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"npgo/internal/lockfile"
+)
+
+func TestLockfileDrift(t *testing.T) {
+	locked := func() *lockfile.LockFile {
+		return &lockfile.LockFile{
+			Importers: map[string]lockfile.Importer{
+				".": {Dependencies: map[string]lockfile.Edge{
+					"a": {Spec: "^1", Version: "1.2.0"},
+					"b": {Spec: "^2", Version: "2.0.0"},
+				}},
+			},
+			Packages: []lockfile.PackageEntry{
+				{Name: "a", Version: "1.2.0"},
+				{Name: "b", Version: "2.0.0"},
+			},
+		}
+	}
+	tests := []struct {
+		name string
+		root map[string]string
+		edit func(lf *lockfile.LockFile)
+		want []Drift
+	}{
+		{
+			name: "unchanged",
+			root: map[string]string{"a": "^1", "b": "^2"},
+		},
+		{
+			name: "added",
+			root: map[string]string{"a": "^1", "b": "^2", "c": "^3"},
+			want: []Drift{{Importer: ".", Name: "c", Want: "^3"}},
+		},
+		{
+			name: "removed",
+			root: map[string]string{"a": "^1"},
+			want: []Drift{{Importer: ".", Name: "b", Locked: "^2", Version: "2.0.0"}},
+		},
+		{
+			name: "changed but still satisfied",
+			root: map[string]string{"a": "~1.2.0", "b": "^2"},
+		},
+		{
+			name: "changed",
+			root: map[string]string{"a": "^1", "b": "^3"},
+			want: []Drift{{Importer: ".", Name: "b", Want: "^3", Locked: "^2", Version: "2.0.0"}},
+		},
+		{
+			name: "overrides changed",
+			root: map[string]string{"a": "^1", "b": "^2"},
+			edit: func(lf *lockfile.LockFile) { lf.OverridesHash = "0123" },
+			want: []Drift{{Importer: ".", Overrides: true}},
+		},
+		{
+			name: "workspace removed",
+			root: map[string]string{"a": "^1", "b": "^2"},
+			edit: func(lf *lockfile.LockFile) { lf.Importers["packages/old"] = lockfile.Importer{} },
+			want: []Drift{{Importer: "packages/old"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lf := locked()
+			if tt.edit != nil {
+				tt.edit(lf)
+			}
+			r := NewResolver()
+			got := r.LockfileDrift(lf, tt.root)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LockfileDrift = %+v, want %+v", got, tt.want)
+			}
+			_, err := r.LoadLockfile(lf, tt.root, t.TempDir())
+			if stale := errors.Is(err, ErrLockfileStale); stale != (len(tt.want) > 0) || (!stale && err != nil) {
+				t.Errorf("LoadLockfile = %v, want stale %v", err, len(tt.want) > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package resolver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"npgo/internal/packagejson"
+	"npgo/internal/semver"
+)
+
+// selectorPart is one package in an override selector, optionally
+// qualified by a version range ("foo@^1").
+type selectorPart struct {
+	name string
+	rng  *semver.Range
+	// direct requires the next part to be an immediate dependency of this one
+	direct bool
+}
+
+func (p selectorPart) matches(d *Dependency) bool {
+	if d.Name != p.name {
+		return false
+	}
+	if p.rng == nil {
+		return true
+	}
+	v, err := semver.Parse(d.Resolved)
+	return err == nil && p.rng.Satisfies(v)
+}
+
+// Override forces the spec of every dependency edge its selector matches
+type Override struct {
+	// Selector is the rule as written, e.g. "overrides[webpack>terser]"
+	Selector string
+	Spec     string
+	parts    []selectorPart
+}
+
+// OverridesFromPackageJSON collects npm "overrides" and yarn "resolutions".
+// npm keys may be nested objects (with "." for the package itself),
+// "parent>child" chains and "$name" references to direct dependency specs;
+// yarn keys use "parent/child" and "**/" globs.
+func OverridesFromPackageJSON(pkg *packagejson.PackageJSON) ([]Override, error) {
+	direct := pkg.GetDependencies()
+	var rules []Override
+	if err := parseNpmOverrides(pkg.Overrides, nil, direct, &rules); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(pkg.Resolutions))
+	for k := range pkg.Resolutions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts, err := parseYarnSelector(k)
+		if err != nil {
+			return nil, err
+		}
+		spec, err := resolveOverrideRef(pkg.Resolutions[k], direct)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, Override{Selector: "resolutions[" + k + "]", Spec: spec, parts: parts})
+	}
+	return rules, nil
+}
+
+func parseNpmOverrides(m map[string]interface{}, prefix []selectorPart, direct map[string]string, out *[]Override) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		chain, err := parseChainSelector(k)
+		if err != nil {
+			return err
+		}
+		parts := append(append([]selectorPart{}, prefix...), chain...)
+		switch v := m[k].(type) {
+		case string:
+			if err := addOverride(out, parts, v, direct); err != nil {
+				return err
+			}
+		case map[string]interface{}:
+			if self, ok := v["."].(string); ok {
+				if err := addOverride(out, parts, self, direct); err != nil {
+					return err
+				}
+			}
+			nested := make(map[string]interface{}, len(v))
+			for nk, nv := range v {
+				if nk != "." {
+					nested[nk] = nv
+				}
+			}
+			if err := parseNpmOverrides(nested, parts, direct, out); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid override for %q", k)
+		}
+	}
+	return nil
+}
+
+func addOverride(out *[]Override, parts []selectorPart, value string, direct map[string]string) error {
+	spec, err := resolveOverrideRef(value, direct)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+		if p.rng != nil {
+			names[i] += "@" + p.rng.String()
+		}
+	}
+	*out = append(*out, Override{Selector: "overrides[" + strings.Join(names, ">") + "]", Spec: spec, parts: parts})
+	return nil
+}
+
+// resolveOverrideRef expands "$name" to the spec of a direct dependency
+func resolveOverrideRef(value string, direct map[string]string) (string, error) {
+	if !strings.HasPrefix(value, "$") {
+		return value, nil
+	}
+	spec, ok := direct[value[1:]]
+	if !ok {
+		return "", fmt.Errorf("override reference %s does not name a direct dependency", value)
+	}
+	return spec, nil
+}
+
+// parseChainSelector parses "a>b@^2"; nested npm objects match anywhere in
+// the subtree, while ">" requires an immediate dependency.
+func parseChainSelector(key string) ([]selectorPart, error) {
+	segs := strings.Split(key, ">")
+	parts := make([]selectorPart, 0, len(segs))
+	for i, seg := range segs {
+		p, err := parseSelectorPart(strings.TrimSpace(seg))
+		if err != nil {
+			return nil, err
+		}
+		p.direct = i < len(segs)-1
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// parseYarnSelector parses "a/b", "**/b", "a/**/b" and scoped names
+func parseYarnSelector(key string) ([]selectorPart, error) {
+	var names []string
+	segs := strings.Split(key, "/")
+	for i := 0; i < len(segs); i++ {
+		if strings.HasPrefix(segs[i], "@") && i+1 < len(segs) {
+			names = append(names, segs[i]+"/"+segs[i+1])
+			i++
+			continue
+		}
+		names = append(names, segs[i])
+	}
+	var parts []selectorPart
+	glob := false
+	for _, n := range names {
+		if n == "**" || n == "*" {
+			glob = true
+			continue
+		}
+		if len(parts) > 0 {
+			parts[len(parts)-1].direct = !glob
+		}
+		p, err := parseSelectorPart(n)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+		glob = false
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid resolution selector %q", key)
+	}
+	return parts, nil
+}
+
+func parseSelectorPart(s string) (selectorPart, error) {
+	if s == "" {
+		return selectorPart{}, fmt.Errorf("empty override selector")
+	}
+	at := strings.LastIndex(s, "@")
+	if at <= 0 {
+		return selectorPart{name: s}, nil
+	}
+	rng, err := semver.ParseRange(s[at+1:])
+	if err != nil {
+		return selectorPart{}, fmt.Errorf("invalid override selector %q: %w", s, err)
+	}
+	return selectorPart{name: s[:at], rng: rng}, nil
+}
+
+// SetOverrides installs the override rules applied by BuildGraph
+func (r *Resolver) SetOverrides(rules []Override) {
+	r.overrides = rules
+}
+
+// OverridesHash fingerprints the override rules for the lockfile, so that
+// editing overrides or resolutions invalidates it; "" when there are none.
+// Selector parts are hashed with their ">" flags, which the Selector text
+// does not tell apart for nested npm objects.
+func (r *Resolver) OverridesHash() string {
+	if len(r.overrides) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, o := range r.overrides {
+		fmt.Fprintf(h, "%s=%s", o.Selector, o.Spec)
+		for _, p := range o.parts {
+			rng := ""
+			if p.rng != nil {
+				rng = p.rng.String()
+			}
+			fmt.Fprintf(h, "|%s@%s/%t", p.name, rng, p.direct)
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// matchState is a selector partially matched by a node's ancestors: rule
+// indexes r.overrides and matched counts the ancestor parts already seen
+type matchState struct {
+	rule, matched int
+}
+
+// overrideState is the set of partial matches in effect below a node, sorted.
+// Nodes reached under different states may need different dependencies, so
+// the state is part of their identity while BuildGraph runs.
+type overrideState []matchState
+
+func (s overrideState) String() string {
+	parts := make([]string, len(s))
+	for i, m := range s {
+		parts[i] = fmt.Sprintf("%d:%d", m.rule, m.matched)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s overrideState) has(rule, matched int) bool {
+	for _, m := range s {
+		if m.rule == rule && m.matched == matched {
+			return true
+		}
+	}
+	return false
+}
+
+// advance returns the state below d given the state d was reached in. A
+// selector moves one part on when d matches its next ancestor part; a partial
+// match carries on past d unless its last matched part is followed by ">",
+// which demands an immediate dependency.
+func (r *Resolver) advance(in overrideState, d *Dependency) overrideState {
+	next := make(map[matchState]bool)
+	step := func(rule, matched int) {
+		parts := r.overrides[rule].parts
+		if matched < len(parts)-1 && parts[matched].matches(d) {
+			next[matchState{rule, matched + 1}] = true
+		}
+	}
+	for i, o := range r.overrides {
+		if len(o.parts) > 1 {
+			step(i, 0)
+		}
+	}
+	for _, m := range in {
+		step(m.rule, m.matched)
+		if !r.overrides[m.rule].parts[m.matched-1].direct {
+			next[m] = true
+		}
+	}
+	if len(next) == 0 {
+		return nil
+	}
+	out := make(overrideState, 0, len(next))
+	for m := range next {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].rule != out[j].rule {
+			return out[i].rule < out[j].rule
+		}
+		return out[i].matched < out[j].matched
+	})
+	return out
+}
+
+// selects reports whether a partial match in state could involve a package
+// named like d, whatever its version
+func (r *Resolver) selects(state overrideState, d *Dependency) bool {
+	for _, m := range state {
+		parts := r.overrides[m.rule].parts
+		if !parts[m.matched-1].direct || parts[m.matched].name == d.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeLabel names the selectors behind a state, e.g. "overrides[a>y]"
+func (r *Resolver) scopeLabel(s overrideState) string {
+	var names []string
+	seen := make(map[int]bool)
+	for _, m := range s {
+		if !seen[m.rule] {
+			seen[m.rule] = true
+			names = append(names, r.overrides[m.rule].Selector)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// applyOverride returns the rule that governs an edge name@spec leaving a
+// node whose state is state, if any; the most specific (longest) selector
+// wins.
+func (r *Resolver) applyOverride(state overrideState, name, spec string) *Override {
+	var best *Override
+	for i := range r.overrides {
+		o := &r.overrides[i]
+		target := o.parts[len(o.parts)-1]
+		if target.name != name {
+			continue
+		}
+		if best != nil && len(o.parts) <= len(best.parts) {
+			continue
+		}
+		if len(o.parts) > 1 && !state.has(i, len(o.parts)-1) {
+			continue
+		}
+		if target.rng != nil {
+			// the qualifier applies to the version the edge would otherwise get
+			d, err := r.resolveDependency(name, spec)
+			if err != nil || !target.matches(d) {
+				continue
+			}
+		}
+		best = o
+	}
+	return best
+}
+
+// mergeScoped folds override-scoped copies back into the shared node wherever
+// they end up with the same dependencies all the way down, so only copies
+// that really differ keep a Scope. visited holds the edge keys BuildGraph
+// expanded; a copy of a package whose shared node was never expanded takes
+// its place.
+func (r *Resolver) mergeScoped(visited map[string]bool) {
+	var nodes []*Dependency
+	index := make(map[*Dependency]int)
+	add := func(d *Dependency) {
+		if _, ok := index[d]; !ok && d != nil {
+			index[d] = len(nodes)
+			nodes = append(nodes, d)
+		}
+	}
+	keys := make([]string, 0, len(r.scoped))
+	for key := range r.scoped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for key := range visited {
+		if !strings.Contains(key, "\x00") {
+			add(r.nodeAt(key))
+		}
+	}
+	for _, key := range keys {
+		add(r.scoped[key])
+	}
+
+	// partition refinement: start from the package contents, then split
+	// classes until every member has children of the same classes
+	var class []int
+	refine := func(sig func(i int) string) int {
+		ids := make(map[string]int)
+		next := make([]int, len(nodes))
+		for i := range nodes {
+			k := sig(i)
+			id, ok := ids[k]
+			if !ok {
+				id = len(ids)
+				ids[k] = id
+			}
+			next[i] = id
+		}
+		class = next
+		return len(ids)
+	}
+	count := refine(func(i int) string {
+		d := nodes[i]
+		var b strings.Builder
+		b.WriteString(d.Name + "@" + d.Resolved + "\x00" + d.LocalPath)
+		for _, cn := range sortedKeys(d.RawDeps) {
+			b.WriteString("\x00" + cn + "=" + d.RawDeps[cn])
+		}
+		return b.String()
+	})
+	for {
+		prev := append([]int(nil), class...)
+		n := refine(func(i int) string {
+			d := nodes[i]
+			var b strings.Builder
+			fmt.Fprintf(&b, "%d", prev[i])
+			for _, cn := range sortedKeys(d.RawDeps) {
+				c := -1
+				if j, ok := index[r.child(d, cn)]; ok {
+					c = prev[j]
+				}
+				fmt.Fprintf(&b, ",%s=%d", cn, c)
+			}
+			return b.String()
+		})
+		if n == count {
+			break
+		}
+		count = n
+	}
+
+	// the shared nodes represent their class; a copy equal to one of them
+	// is replaced, otherwise it becomes the representative itself
+	rep := make(map[int]*Dependency)
+	shared := make(map[string]bool)
+	for key := range visited {
+		if !strings.Contains(key, "\x00") {
+			if d := r.nodeAt(key); d != nil {
+				rep[class[index[d]]] = d
+				shared[d.Name+"@"+d.Resolved] = true
+			}
+		}
+	}
+	replaced := make(map[*Dependency]*Dependency)
+	for _, key := range keys {
+		v := r.scoped[key]
+		base := key[:strings.IndexByte(key, 0)]
+		if rp := rep[class[index[v]]]; rp != nil {
+			if rp.Override == "" {
+				rp.Override = v.Override
+			}
+			r.scoped[key] = rp
+			replaced[v] = rp
+			continue
+		}
+		rep[class[index[v]]] = v
+		if id := v.Name + "@" + v.Resolved; !visited[base] && !shared[id] {
+			// nothing outside the scope uses this version: share the copy
+			v.Scope = ""
+			r.cache.Store(base, v)
+			visited[base] = true
+			shared[id] = true
+		}
+	}
+
+	// name the copies that remain; equal labels get a counter
+	labels := make(map[string]int)
+	named := make(map[*Dependency]bool)
+	for _, key := range keys {
+		v := r.scoped[key]
+		if d, _ := r.cache.Get(key[:strings.IndexByte(key, 0)]); d == v {
+			delete(r.scoped, key)
+			continue
+		}
+		if named[v] || v.Scope == "" {
+			continue
+		}
+		named[v] = true
+		label := r.scopeLabel(v.state)
+		id := v.Name + "@" + v.Resolved + "(" + label + ")"
+		if labels[id]++; labels[id] > 1 {
+			label = fmt.Sprintf("%s#%d", label, labels[id])
+		}
+		v.Scope = label
+	}
+	for key, parents := range r.parents {
+		for i, p := range parents {
+			if rp, ok := replaced[p]; ok {
+				parents[i] = rp
+			}
+		}
+		r.parents[key] = parents
+	}
+}
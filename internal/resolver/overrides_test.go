@@ -0,0 +1,200 @@
+package resolver
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"npgo/internal/packagejson"
+	"npgo/internal/registry"
+)
+
+func overridesRegistry(t *testing.T) *registry.Memory {
+	t.Helper()
+	mem := registry.NewMemory()
+	for _, md := range []registry.PackageMetadata{
+		{Name: "a", Version: "1.0.0", Dependencies: map[string]string{"b": "^1"}},
+		{Name: "b", Version: "1.0.0", Dependencies: map[string]string{"c": "^1"}},
+		{Name: "b", Version: "2.0.0"},
+		{Name: "c", Version: "1.0.0"},
+		{Name: "c", Version: "1.1.0"},
+		{Name: "c", Version: "2.0.0"},
+		{Name: "x", Version: "1.0.0", Dependencies: map[string]string{"c": "^1"}},
+	} {
+		if err := mem.Add(md, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mem
+}
+
+// walkGraph maps each dependency path ("a/b/c") to its version, with the
+// scope of override-scoped copies in parentheses
+func walkGraph(r *Resolver, roots map[string]string) map[string]string {
+	got := make(map[string]string)
+	var walk func(path string, d *Dependency, depth int)
+	walk = func(path string, d *Dependency, depth int) {
+		v := d.Resolved
+		if d.Scope != "" {
+			v += "(" + d.Scope + ")"
+		}
+		got[path] = v
+		if depth > 8 {
+			return
+		}
+		for _, cn := range sortedKeys(d.RawDeps) {
+			if c := r.child(d, cn); c != nil {
+				walk(path+"/"+cn, c, depth+1)
+			}
+		}
+	}
+	for name, spec := range roots {
+		if d := r.Lookup(name, spec); d != nil {
+			walk(name, d, 0)
+		}
+	}
+	return got
+}
+
+func TestOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkgJSON string
+		want    map[string]string
+	}{
+		{
+			name:    "global override",
+			pkgJSON: `{"dependencies":{"a":"^1"},"overrides":{"c":"2.0.0"}}`,
+			want:    map[string]string{"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "2.0.0"},
+		},
+		{
+			name:    "chain selector only reaches immediate dependencies",
+			pkgJSON: `{"dependencies":{"a":"^1","x":"^1"},"overrides":{"b>c":"1.0.0","a>c":"2.0.0"}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "1.0.0",
+				"x": "1.0.0", "x/c": "1.1.0",
+			},
+		},
+		{
+			name:    "chain selector replaces a direct dependency's spec",
+			pkgJSON: `{"dependencies":{"a":"^1","b":"^1"},"overrides":{"a>b":"2.0.0"}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "2.0.0",
+				"b": "1.0.0", "b/c": "1.1.0",
+			},
+		},
+		{
+			name:    "version-qualified selector",
+			pkgJSON: `{"dependencies":{"a":"^1","x":"^1"},"overrides":{"c@<1.1.0":"2.0.0","b":{"c@^1":"1.0.0"}}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "1.0.0",
+				"x": "1.0.0", "x/c": "1.1.0",
+			},
+		},
+		{
+			name:    "reference to a direct dependency",
+			pkgJSON: `{"dependencies":{"a":"^1","c":"2.0.0"},"overrides":{"c":"$c"}}`,
+			want:    map[string]string{"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "2.0.0", "c": "2.0.0"},
+		},
+		{
+			name:    "yarn glob resolution",
+			pkgJSON: `{"dependencies":{"a":"^1","x":"^1"},"resolutions":{"**/c":"1.0.0"}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "1.0.0",
+				"x": "1.0.0", "x/c": "1.0.0",
+			},
+		},
+		{
+			name:    "yarn path resolution",
+			pkgJSON: `{"dependencies":{"a":"^1","x":"^1"},"resolutions":{"a/**/c":"2.0.0"}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "2.0.0",
+				"x": "1.0.0", "x/c": "1.1.0",
+			},
+		},
+		{
+			name:    "nested override scopes a copy beside the shared node",
+			pkgJSON: `{"dependencies":{"a":"^1","b":"^1"},"overrides":{"a":{"c":"1.0.0"}}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "1.0.0(overrides[a>c])", "a/b/c": "1.0.0",
+				"b": "1.0.0", "b/c": "1.1.0",
+			},
+		},
+		{
+			name:    "scoped copy equal to the shared node is merged",
+			pkgJSON: `{"dependencies":{"a":"^1","b":"^1"},"overrides":{"a":{"c":"^1"}}}`,
+			want: map[string]string{
+				"a": "1.0.0", "a/b": "1.0.0", "a/b/c": "1.1.0",
+				"b": "1.0.0", "b/c": "1.1.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pkg packagejson.PackageJSON
+			if err := json.Unmarshal([]byte(tt.pkgJSON), &pkg); err != nil {
+				t.Fatal(err)
+			}
+			rules, err := OverridesFromPackageJSON(&pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewResolver()
+			r.SetMetadataSource(overridesRegistry(t))
+			r.SetOverrides(rules)
+			if _, err := r.BuildGraph(pkg.Dependencies); err != nil {
+				t.Fatal(err)
+			}
+			if got := walkGraph(r, pkg.Dependencies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("graph = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverridesKeepDeclaredSpec(t *testing.T) {
+	var pkg packagejson.PackageJSON
+	if err := json.Unmarshal([]byte(`{"dependencies":{"a":"^1"},"overrides":{"b>c":"2.0.0"}}`), &pkg); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := OverridesFromPackageJSON(&pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewResolver()
+	r.SetMetadataSource(overridesRegistry(t))
+	r.SetOverrides(rules)
+	if _, err := r.BuildGraph(pkg.Dependencies); err != nil {
+		t.Fatal(err)
+	}
+	b := r.child(r.Lookup("a", "^1"), "b")
+	if b == nil {
+		t.Fatal("a has no b")
+	}
+	if got := b.RawDeps["c"]; got != "2.0.0" {
+		t.Errorf("b's effective spec for c = %q, want 2.0.0", got)
+	}
+	if got := b.DeclaredSpec("c"); got != "^1" {
+		t.Errorf("b's declared spec for c = %q, want ^1", got)
+	}
+	if c := r.child(b, "c"); c == nil || c.Override != "overrides[b>c]" {
+		t.Errorf("c = %+v, want Override overrides[b>c]", c)
+	}
+}
+
+func TestOverridesInvalid(t *testing.T) {
+	for _, pkgJSON := range []string{
+		`{"overrides":{"c":"$missing"}}`,
+		`{"overrides":{"c@not a range":"1.0.0"}}`,
+		`{"overrides":{"c":1}}`,
+		`{"resolutions":{"**":"1.0.0"}}`,
+	} {
+		var pkg packagejson.PackageJSON
+		if err := json.Unmarshal([]byte(pkgJSON), &pkg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OverridesFromPackageJSON(&pkg); err == nil {
+			t.Errorf("OverridesFromPackageJSON(%s) succeeded", pkgJSON)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"npgo/internal/semver"
+)
+
+// PeerIssue describes a peer dependency that is missing or out of range
+type PeerIssue struct {
+	Package string // name@version of the dependent
+	Peer    string
+	Range   string
+	Found   string // version visible to the dependent; "" when missing
+}
+
+func (p PeerIssue) String() string {
+	if p.Found == "" {
+		return fmt.Sprintf("%s requires peer %s@%s, which is not installed", p.Package, p.Peer, p.Range)
+	}
+	return fmt.Sprintf("%s requires peer %s@%s, but %s is installed", p.Package, p.Peer, p.Range, p.Found)
+}
+
+// PeerIssues returns the unmet peers found by the last BuildGraph
+func (r *Resolver) PeerIssues() []PeerIssue {
+	return r.peerIssues
+}
+
+func peerError(issues []PeerIssue) error {
+	lines := make([]string, len(issues))
+	for i, is := range issues {
+		lines[i] = is.String()
+	}
+	return fmt.Errorf("unmet peer dependencies:\n  %s", strings.Join(lines, "\n  "))
+}
+
+type missingPeer struct {
+	dependent *Dependency
+	name, rng string
+}
+
+// missingPeers lists required peers that nothing above their dependent provides
+func (r *Resolver) missingPeers(root map[string]string, graph map[string]*Dependency) []missingPeer {
+	r.indexParents()
+	var out []missingPeer
+	for _, key := range sortedGraphKeys(graph) {
+		d := graph[key]
+		for _, peer := range sortedKeys(d.PeerDeps) {
+			if d.OptionalPeers[peer] {
+				continue
+			}
+			// already requested as a regular or auto-installed dependency
+			if _, ok := d.RawDeps[peer]; ok {
+				continue
+			}
+			if r.visiblePeer(d, peer, root) == nil {
+				out = append(out, missingPeer{dependent: d, name: peer, rng: d.PeerDeps[peer]})
+			}
+		}
+	}
+	return out
+}
+
+// checkPeers validates every declared peer against the version its dependent can see
+func (r *Resolver) checkPeers(root map[string]string, graph map[string]*Dependency) []PeerIssue {
+	r.indexParents()
+	var issues []PeerIssue
+	for _, key := range sortedGraphKeys(graph) {
+		d := graph[key]
+		for _, peer := range sortedKeys(d.PeerDeps) {
+			rng := d.PeerDeps[peer]
+			found := r.visiblePeer(d, peer, root)
+			if found == nil {
+				found = r.child(d, peer)
+			}
+			issue := PeerIssue{Package: d.Name + "@" + d.Resolved, Peer: peer, Range: rng}
+			if found == nil {
+				if !d.OptionalPeers[peer] {
+					issues = append(issues, issue)
+				}
+				continue
+			}
+			if !semver.Satisfies(found.Resolved, rng) {
+				issue.Found = found.Resolved
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// visiblePeer finds the nearest copy of name provided by one of d's
+// ancestors (their dependencies), falling back to the root dependencies.
+func (r *Resolver) visiblePeer(d *Dependency, name string, root map[string]string) *Dependency {
+	seen := map[*Dependency]bool{d: true}
+	queue := r.parentsOf(d)
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		if a == nil || seen[a] {
+			continue
+		}
+		seen[a] = true
+		if found := r.child(a, name); found != nil {
+			return found
+		}
+		queue = append(queue, r.parentsOf(a)...)
+	}
+	if spec, ok := root[name]; ok {
+		return r.Lookup(name, spec)
+	}
+	return nil
+}
+
+func (r *Resolver) parentsOf(d *Dependency) []*Dependency {
+	return r.parentIndex[d]
+}
+
+// indexParents maps each resolved dependency to its dependents
+func (r *Resolver) indexParents() {
+	r.parentIndex = make(map[*Dependency][]*Dependency)
+	for key, parents := range r.parents {
+		if d := r.nodeAt(key); d != nil {
+			r.parentIndex[d] = append(r.parentIndex[d], parents...)
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedGraphKeys(graph map[string]*Dependency) []string {
+	keys := make([]string, 0, len(graph))
+	for k := range graph {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
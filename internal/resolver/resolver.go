@@ -1,17 +1,17 @@
 package resolver
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 
+	"npgo/internal/memo"
 	"npgo/internal/packagejson"
+	"npgo/internal/platform"
 	"npgo/internal/registry"
 	"npgo/internal/ui"
+	"npgo/internal/workspace"
 )
 
 type Dependency struct {
@@ -21,28 +21,115 @@ type Dependency struct {
 	TarballURL   string
 	Dependencies map[string]*Dependency
 	RawDeps      map[string]string
+	// PeerDeps are the declared peer ranges; OptionalPeers marks the ones
+	// flagged optional in peerDependenciesMeta.
+	PeerDeps      map[string]string
+	OptionalPeers map[string]bool
+	// OptionalDeps marks the RawDeps entries that came from optionalDependencies
+	OptionalDeps map[string]bool
+	OS           []string
+	CPU          []string
+	Libc         []string
+	// Override is the selector of the override rule that produced this node
+	Override string
+	// Source records where a non-registry package came from (git URL#sha,
+	// tarball URL, file:, link: or npm: alias); empty for registry packages
+	Source string
+	// LocalPath is the package directory for non-registry packages: a CAS
+	// entry, or the linked directory itself for link: and workspace members
+	LocalPath string
+	// Integrity is the registry SRI of the tarball; StoreHash is the npgo CAS
+	// hash, known up front only for packages imported during resolution
+	Integrity        string
+	StoreHash        string
+	Engines          map[string]string
+	Bin              map[string]string
+	HasInstallScript bool
+	// Linked packages are symlinked to LocalPath rather than materialized;
+	// their own nested dependencies land in LocalPath/node_modules
+	Linked bool
+	// Deprecated is the registry deprecation message, empty when not deprecated
+	Deprecated string
+	// Scope names the override selectors this copy was resolved under, when
+	// they give it different dependencies from the shared node of the same
+	// version (e.g. a nested npm override); empty for the shared node
+	Scope string
+
+	// declared are the dependency specs before overrides; state is the
+	// override state below this node
+	declared map[string]string
+	state    overrideState
+	// variants holds the scoped copies some dependencies resolve to, by name
+	variants map[string]*Dependency
 }
 
 type Resolver struct {
-	cache       map[string]*Dependency
+	// cache memoizes resolutions by name@spec; concurrent resolutions of the
+	// same edge share one fetch
+	cache       memo.Map[*Dependency]
 	debug       bool
 	concurrency int
 	onProgress  func(string)
+
+	autoInstallPeers bool
+	strictPeers      bool
+	peerIssues       []PeerIssue
+	warnings         []string
+	// skipped holds name@spec edges left out of the graph (platform mismatch)
+	skipped   map[string]bool
+	overrides []Override
+	// parents records, per name@spec edge, which dependents requested it (nil = root)
+	parents     map[string][]*Dependency
+	parentIndex map[*Dependency][]*Dependency
+	// failures collects required edges that failed to resolve
+	failures []*ResolveError
+	// unresolved holds the resolution error of every name@spec edge that
+	// failed during the last BuildGraph, required or not
+	unresolved map[string]error
+	// scoped holds the copies made for edges reached under an override
+	// state, keyed by scopedKey; scopedUse marks the name@spec edges whose
+	// package a partial match could apply to, which dedupe leaves alone
+	scoped    map[string]*Dependency
+	scopedUse map[string]bool
+	rootName  string
+	// workspaces maps member names to their packages in a monorepo
+	workspaces map[string]*workspace.Member
+	// source serves registry metadata and tarballs serves URL tarball specs
+	// (registry.Default unless replaced)
+	source   registry.MetadataSource
+	tarballs registry.TarballSource
 }
 
 func NewResolver() *Resolver {
-	return &Resolver{cache: make(map[string]*Dependency), debug: false, concurrency: 32}
+	return &Resolver{debug: false, concurrency: 32, autoInstallPeers: true, source: registry.Default, tarballs: registry.Default}
 }
 
 func NewResolverWithDebug(debug bool) *Resolver {
-	return &Resolver{cache: make(map[string]*Dependency), debug: debug, concurrency: 32}
+	return &Resolver{debug: debug, concurrency: 32, autoInstallPeers: true, source: registry.Default, tarballs: registry.Default}
 }
 
 func NewResolverWithOptions(debug bool, concurrency int, onProgress func(string)) *Resolver {
 	if concurrency <= 0 {
 		concurrency = 32
 	}
-	return &Resolver{cache: make(map[string]*Dependency), debug: debug, concurrency: concurrency, onProgress: onProgress}
+	return &Resolver{debug: debug, concurrency: concurrency, onProgress: onProgress, autoInstallPeers: true, source: registry.Default, tarballs: registry.Default}
+}
+
+// SetMetadataSource replaces the registry the resolver reads metadata from
+func (r *Resolver) SetMetadataSource(src registry.MetadataSource) {
+	r.source = src
+}
+
+// SetTarballSource replaces where tarball URL dependencies are downloaded from
+func (r *Resolver) SetTarballSource(src registry.TarballSource) {
+	r.tarballs = src
+}
+
+// SetPeerOptions controls whether missing required peers are installed
+// automatically and whether unmet peers fail BuildGraph.
+func (r *Resolver) SetPeerOptions(autoInstall, strict bool) {
+	r.autoInstallPeers = autoInstall
+	r.strictPeers = strict
 }
 
 func (r *Resolver) ResolveDependencies(pkg *packagejson.PackageJSON) ([]*Dependency, error) {
@@ -78,25 +165,50 @@ func (r *Resolver) ResolveDevDependencies(pkg *packagejson.PackageJSON, include
 }
 
 func (r *Resolver) resolveDependency(name, spec string) (*Dependency, error) {
-	if cached, exists := r.cache[name+"@"+spec]; exists {
-		return cached, nil
-	}
+	return r.cache.Do(name+"@"+spec, func() (*Dependency, error) {
+		return r.resolveUncached(name, spec)
+	})
+}
 
-	version := normalizeVersion(spec)
-	if r.debug {
-		ui.InstallStep("🧭", fmt.Sprintf("Resolving %s (spec: %s → %s)", name, spec, version))
+func (r *Resolver) resolveUncached(name, spec string) (*Dependency, error) {
+	var dep *Dependency
+	proto := specProtocol(spec)
+	if proto == protoRegistry && r.workspaceSatisfies(name, spec) {
+		// a member whose version satisfies the range is linked, not downloaded
+		proto = protoWorkspace
 	}
-
-	metadata, err := r.getMetadataCached(name, version)
-	if err != nil {
+	if proto != protoRegistry {
+		if r.debug {
+			ui.InstallStep("🧭", fmt.Sprintf("Resolving %s (%s spec: %s)", name, proto, spec))
+		}
+		d, err := r.resolveSource(name, spec, proto)
+		if err != nil {
+			return nil, err
+		}
+		dep = d
+	} else {
+		version := normalizeVersion(spec)
 		if r.debug {
-			ui.ErrorMessage(fmt.Errorf("resolve failed %s@%s: %v", name, version, err))
+			ui.InstallStep("🧭", fmt.Sprintf("Resolving %s (spec: %s → %s)", name, spec, version))
 		}
-		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+
+		metadata, err := r.metadata(name, version)
+		if err != nil {
+			if r.debug {
+				ui.ErrorMessage(fmt.Errorf("resolve failed %s@%s: %v", name, version, err))
+			}
+			return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+		}
+		dep = newDependency(name, spec, metadata)
 	}
+	return dep, nil
+}
 
-	// merge dependencies + optionalDependencies
+// newDependency builds a graph node from a version's metadata
+func newDependency(name, spec string, metadata *registry.PackageMetadata) *Dependency {
+	// merge dependencies + optionalDependencies; optional entries are tagged
 	raw := make(map[string]string)
+	optional := make(map[string]bool)
 	if metadata.Dependencies != nil {
 		for k, v := range metadata.Dependencies {
 			raw[k] = v
@@ -105,84 +217,146 @@ func (r *Resolver) resolveDependency(name, spec string) (*Dependency, error) {
 	if metadata.OptionalDependencies != nil {
 		for k, v := range metadata.OptionalDependencies {
 			raw[k] = v
+			optional[k] = true
 		}
 	}
 
 	dep := &Dependency{
-		Name:         name,
-		Spec:         spec,
-		Resolved:     metadata.Version,
-		TarballURL:   metadata.TarballURL,
-		Dependencies: make(map[string]*Dependency),
-		RawDeps:      raw,
+		Name:          name,
+		Spec:          spec,
+		Resolved:      metadata.Version,
+		TarballURL:    metadata.TarballURL,
+		Dependencies:  make(map[string]*Dependency),
+		RawDeps:       raw,
+		declared:      raw,
+		PeerDeps:      metadata.PeerDependencies,
+		OptionalPeers: make(map[string]bool),
+		OptionalDeps:  optional,
+		OS:            metadata.OS,
+		CPU:           metadata.CPU,
+		Libc:          metadata.Libc,
+
+		Integrity:        metadata.SRI(),
+		Engines:          metadata.EngineRanges(),
+		Bin:              metadata.BinMap(),
+		HasInstallScript: metadata.InstallScript(),
+		Deprecated:       metadata.DeprecationMessage(),
 	}
-
-	r.cache[name+"@"+spec] = dep
-
-	return dep, nil
+	for peer, meta := range metadata.PeerDependenciesMeta {
+		if meta.Optional {
+			dep.OptionalPeers[peer] = true
+		}
+	}
+	return dep
 }
 
+// normalizeVersion canonicalizes a dependency spec; range matching itself
+// happens in the registry against the full version list.
 func normalizeVersion(spec string) string {
-	spec = strings.TrimPrefix(spec, "^")
-	spec = strings.TrimPrefix(spec, "~")
-	spec = strings.TrimPrefix(spec, ">=")
-	spec = strings.TrimPrefix(spec, "<=")
-	spec = strings.TrimPrefix(spec, ">")
-	spec = strings.TrimPrefix(spec, "<")
-
-	// lite normalization to increase cache hit
-	if spec == "1.x" || spec == "1.*" {
-		return "1"
-	}
-	if strings.HasSuffix(spec, ".x") || strings.HasSuffix(spec, ".*") {
-		spec = strings.TrimSuffix(strings.TrimSuffix(spec, ".x"), ".*")
+	spec = strings.Join(strings.Fields(spec), " ")
+	if spec == "" || spec == "latest" {
+		return "latest"
 	}
+	return spec
+}
 
-	if strings.Contains(spec, " ") {
-		parts := strings.Fields(spec)
-		spec = parts[0]
-	}
+// metadata asks the resolver's MetadataSource for the version a spec selects
+func (r *Resolver) metadata(name, version string) (*registry.PackageMetadata, error) {
+	return r.source.Metadata(name, version)
+}
 
-	if spec == "" || spec == "*" || spec == "latest" {
-		return "latest"
-	}
+// DeclaredSpec returns the spec d's manifest gives for dependency name,
+// before overrides; "" when name was added by the resolver (auto-installed
+// peers)
+func (d *Dependency) DeclaredSpec(name string) string {
+	return d.declared[name]
+}
 
-	return spec
+// Lookup returns the shared dependency resolved for name@spec, or nil
+func (r *Resolver) Lookup(name, spec string) *Dependency {
+	if r.skipped[name+"@"+spec] {
+		return nil
+	}
+	d, _ := r.cache.Get(name + "@" + spec)
+	return d
 }
 
-// per-version metadata cache under ~/.npgo/registry-cache/versions
-func (r *Resolver) getMetadataCached(name, version string) (*registry.PackageMetadata, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, err
+// child returns the dependency d's edge to name leads to, which is an
+// override-scoped copy when d's ancestors call for one, or nil
+func (r *Resolver) child(d *Dependency, name string) *Dependency {
+	spec, ok := d.RawDeps[name]
+	if !ok {
+		return nil
 	}
-	dir := filepath.Join(home, ".npgo", "registry-cache", "versions")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, err
+	if v, ok := d.variants[name]; ok {
+		return v
 	}
-	safe := strings.ReplaceAll(strings.ReplaceAll(name, "/", "-"), "\\", "-")
-	p := filepath.Join(dir, fmt.Sprintf("%s@%s.json", safe, version))
-	if b, err := os.ReadFile(p); err == nil {
-		var md registry.PackageMetadata
-		if json.Unmarshal(b, &md) == nil && md.Version != "" {
-			return &md, nil
+	if len(d.state) > 0 {
+		key := scopedKey(name, spec, d.state)
+		if v := r.scoped[key]; v != nil {
+			if r.skipped[key] {
+				return nil
+			}
+			return v
 		}
 	}
-	md, err := registry.FetchMetadata(name, version)
-	if err != nil {
-		return nil, err
+	return r.Lookup(name, spec)
+}
+
+// scopedKey identifies the edge name@spec reached under state
+func scopedKey(name, spec string, state overrideState) string {
+	key := name + "@" + spec
+	if len(state) > 0 {
+		key += "\x00" + state.String()
 	}
-	if data, err := json.MarshalIndent(md, "", "  "); err == nil {
-		_ = os.WriteFile(p, data, 0644)
+	return key
+}
+
+// nodeAt returns the node an edge key (see scopedKey) resolved to
+func (r *Resolver) nodeAt(key string) *Dependency {
+	if v := r.scoped[key]; v != nil {
+		return v
+	}
+	if i := strings.IndexByte(key, 0); i >= 0 {
+		key = key[:i]
+	}
+	d, _ := r.cache.Get(key)
+	return d
+}
+
+// nodeKey identifies a package in the graph: name@version, plus the scope of
+// an override-scoped copy
+func nodeKey(d *Dependency) string {
+	if d.Scope != "" {
+		return d.Name + "@" + d.Resolved + "(" + d.Scope + ")"
 	}
-	return md, nil
+	return d.Name + "@" + d.Resolved
+}
+
+// variant copies d for an override state that changes what lies below it
+func (d *Dependency) variant(state overrideState) *Dependency {
+	v := *d
+	v.Dependencies = make(map[string]*Dependency)
+	v.RawDeps = d.declared
+	v.state = state
+	// provisional; mergeScoped names the copies that remain
+	v.Scope = state.String()
+	return &v
+}
+
+// Warnings returns non-fatal problems from the last BuildGraph, such as
+// optional dependencies that were skipped
+func (r *Resolver) Warnings() []string {
+	sort.Strings(r.warnings)
+	return r.warnings
 }
 
 func (r *Resolver) GetAllDependencies() []*Dependency {
 	var deps []*Dependency
-	for _, dep := range r.cache {
+	r.cache.Range(func(_ string, dep *Dependency) bool {
 		deps = append(deps, dep)
-	}
+		return true
+	})
 
 	sort.Slice(deps, func(i, j int) bool {
 		return deps[i].Name < deps[j].Name
@@ -192,18 +366,52 @@ func (r *Resolver) GetAllDependencies() []*Dependency {
 }
 
 func (r *Resolver) BuildGraph(root map[string]string) (map[string]*Dependency, error) {
-	graph := make(map[string]*Dependency)
-	seen := sync.Map{}
+	seen := make(map[string]bool)
 	sem := make(chan struct{}, r.concurrency)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-
-	var visit func(name, spec string)
-	visit = func(name, spec string) {
-		key := name + "@" + spec
-		if _, loaded := seen.LoadOrStore(key, true); loaded {
+	// held maps packages built for another platform to the deferred visit of
+	// their dependencies, which only happens if something requires them
+	held := make(map[*Dependency]func())
+	r.parents = make(map[string][]*Dependency)
+	r.unresolved = make(map[string]error)
+	r.scoped = make(map[string]*Dependency)
+	r.scopedUse = make(map[string]bool)
+
+	var visit func(name, spec string, parent *Dependency, override string)
+	// expand applies the overrides in effect below dep to its declared
+	// dependencies and visits them
+	expand := func(dep *Dependency) {
+		children := make(map[string]string, len(dep.declared))
+		applied := make(map[string]string)
+		for cn, cs := range dep.declared {
+			children[cn] = cs
+			if o := r.applyOverride(dep.state, cn, cs); o != nil && o.Spec != cs {
+				children[cn] = o.Spec
+				applied[cn] = o.Selector
+			}
+		}
+		mu.Lock()
+		dep.RawDeps = children
+		mu.Unlock()
+		for cn, cs := range children {
+			visit(cn, cs, dep, applied[cn])
+		}
+	}
+	visit = func(name, spec string, parent *Dependency, override string) {
+		var in overrideState
+		if parent != nil {
+			in = parent.state
+		}
+		key := scopedKey(name, spec, in)
+		mu.Lock()
+		r.parents[key] = append(r.parents[key], parent)
+		if seen[key] {
+			mu.Unlock()
 			return
 		}
+		seen[key] = true
+		mu.Unlock()
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -211,76 +419,321 @@ func (r *Resolver) BuildGraph(root map[string]string) (map[string]*Dependency, e
 			dep, err := r.resolveDependency(name, spec)
 			<-sem
 			if err != nil {
-				if r.debug {
-					ui.ErrorMessage(fmt.Errorf("resolve %s: %v", key, err))
-				}
+				// whether this fails the build depends on every edge that
+				// reaches it, which is only known once resolution settles
+				mu.Lock()
+				r.unresolved[name+"@"+spec] = err
+				mu.Unlock()
 				return
 			}
 			if r.onProgress != nil {
 				r.onProgress(name + "@" + dep.Resolved)
 			}
+			shared := r.advance(nil, dep)
+			node := dep
 			mu.Lock()
-			graph[name+"@"+dep.Resolved] = dep
-			mu.Unlock()
-			for cn, cs := range dep.RawDeps {
-				visit(cn, cs)
+			if r.selects(in, dep) {
+				r.scopedUse[name+"@"+spec] = true
+			}
+			if out := r.advance(in, dep); out.String() != shared.String() {
+				node = dep.variant(out)
+				r.scoped[key] = node
+			} else if len(in) > 0 {
+				// the ancestors make no difference below this package
+				if seen[name+"@"+spec] {
+					if override != "" {
+						dep.Override = override
+					}
+					mu.Unlock()
+					return
+				}
+				seen[name+"@"+spec] = true
+			}
+			if node == dep {
+				dep.state = shared
 			}
+			if override != "" {
+				node.Override = override
+			}
+			if !platform.Matches(node.OS, node.CPU, node.Libc) {
+				held[node] = func() { expand(node) }
+				mu.Unlock()
+				return
+			}
+			mu.Unlock()
+			expand(node)
 		}()
 	}
 	for n, s := range root {
-		visit(n, s)
+		visit(n, s, nil, "")
+	}
+
+	var graph map[string]*Dependency
+	for {
+		wg.Wait()
+		var required map[*Dependency]bool
+		graph, required = r.classify(root, held)
+		// a required package for another platform is still installed
+		var release []func()
+		for dep, expandHeld := range held {
+			if required[dep] {
+				delete(held, dep)
+				release = append(release, expandHeld)
+			}
+		}
+		for _, expandHeld := range release {
+			expandHeld()
+		}
+		if len(release) > 0 {
+			continue
+		}
+		// auto-installed peers can declare peers of their own; settle until stable
+		if !r.autoInstallPeers {
+			break
+		}
+		missing := r.missingPeers(root, graph)
+		if len(missing) == 0 {
+			break
+		}
+		for _, m := range missing {
+			m.dependent.RawDeps[m.name] = m.rng
+			visit(m.name, m.rng, m.dependent, "")
+		}
+	}
+	if len(r.scoped) > 0 {
+		r.mergeScoped(seen)
+		graph, _ = r.classify(root, held)
+	}
+	if len(r.failures) > 0 {
+		return graph, resolutionError(r.failures)
+	}
+	graph = r.dedupe(root)
+	r.linkEdges(graph)
+	r.peerIssues = r.checkPeers(root, graph)
+	if r.strictPeers && len(r.peerIssues) > 0 {
+		return graph, peerError(r.peerIssues)
 	}
-	wg.Wait()
 	return graph, nil
 }
 
-func TopoOrder(graph map[string]*Dependency) ([]*Dependency, error) {
-	indeg := make(map[*Dependency]int)
-	children := make(map[*Dependency][]*Dependency)
-	nodes := make([]*Dependency, 0, len(graph))
-	for _, d := range graph {
-		nodes = append(nodes, d)
-		if d.Dependencies != nil {
-			for _, c := range d.Dependencies {
-				indeg[c]++
-				children[d] = append(children[d], c)
+// classify walks the settled edges from the root. A package is required when
+// some path reaches it through non-optional edges only: failing to resolve it
+// is an error and an unsupported platform only a warning. Packages reached
+// through optional edges alone are skipped on failure or platform mismatch.
+// It returns the packages that remain and the required ones; held packages
+// are not descended into.
+func (r *Resolver) classify(root map[string]string, held map[*Dependency]func()) (map[string]*Dependency, map[*Dependency]bool) {
+	r.skipped = make(map[string]bool)
+	r.warnings = nil
+	r.failures = nil
+
+	type edge struct {
+		parent     *Dependency
+		name, spec string
+	}
+	var roots []edge
+	for _, n := range sortedKeys(root) {
+		roots = append(roots, edge{name: n, spec: root[n]})
+	}
+	edges := func(d *Dependency, requiredOnly bool) []edge {
+		if _, ok := held[d]; ok {
+			return nil
+		}
+		var out []edge
+		for _, cn := range sortedKeys(d.RawDeps) {
+			if !requiredOnly || !d.OptionalDeps[cn] {
+				out = append(out, edge{parent: d, name: cn, spec: d.RawDeps[cn]})
 			}
 		}
+		return out
 	}
-	q := make([]*Dependency, 0)
-	for _, d := range nodes {
-		if indeg[d] == 0 {
-			q = append(q, d)
+	// target returns the node an edge leads to and the key it is known by
+	target := func(e edge) (*Dependency, string, error) {
+		if e.parent != nil && len(e.parent.state) > 0 {
+			key := scopedKey(e.name, e.spec, e.parent.state)
+			if v := r.scoped[key]; v != nil {
+				return v, key, nil
+			}
+		}
+		key := e.name + "@" + e.spec
+		if d, ok := r.cache.Get(key); ok {
+			return d, key, nil
 		}
+		if err := r.unresolved[key]; err != nil {
+			return nil, key, err
+		}
+		return nil, key, fmt.Errorf("not resolved")
 	}
-	order := make([]*Dependency, 0, len(nodes))
-	for len(q) > 0 {
-		d := q[0]
-		q = q[1:]
-		order = append(order, d)
-		for _, c := range children[d] {
-			indeg[c]--
-			if indeg[c] == 0 {
-				q = append(q, c)
+
+	// required packages, each with the shortest chain of dependents to it
+	chains := make(map[*Dependency][]*Dependency)
+	failed := make(map[string]bool)
+	queue := roots
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		var chain []*Dependency
+		if e.parent != nil {
+			chain = append(append([]*Dependency{}, chains[e.parent]...), e.parent)
+		}
+		d, key, err := target(e)
+		if err != nil {
+			if !failed[key] {
+				failed[key] = true
+				r.failures = append(r.failures, &ResolveError{Path: r.dependencyPath(chain), Name: e.name, Spec: e.spec, Err: err})
 			}
+			continue
 		}
+		if _, ok := chains[d]; ok {
+			continue
+		}
+		chains[d] = chain
+		queue = append(queue, edges(d, true)...)
+	}
+	required := make(map[*Dependency]bool, len(chains))
+	for d := range chains {
+		required[d] = true
 	}
-	seen := make(map[*Dependency]bool)
-	uniq := make([]*Dependency, 0, len(nodes))
-	for _, d := range order {
-		if !seen[d] {
-			seen[d] = true
-			uniq = append(uniq, d)
+
+	graph := make(map[string]*Dependency)
+	reached := make(map[*Dependency]bool)
+	queue = roots
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		d, key, err := target(e)
+		if err != nil {
+			if !failed[key] {
+				failed[key] = true
+				r.warnings = append(r.warnings, fmt.Sprintf("skipping optional dependency %s: %v", key, err))
+			}
+			continue
+		}
+		supported := platform.Matches(d.OS, d.CPU, d.Libc)
+		if !supported && !required[d] {
+			if !r.skipped[key] && r.debug {
+				r.warnings = append(r.warnings, fmt.Sprintf("skipping optional dependency %s@%s: unsupported platform", d.Name, d.Resolved))
+			}
+			r.skipped[key] = true
+			continue
+		}
+		if reached[d] {
+			continue
+		}
+		reached[d] = true
+		if !supported {
+			r.warnings = append(r.warnings, fmt.Sprintf("%s@%s does not support %s/%s", d.Name, d.Resolved, platform.OS(), platform.CPU()))
+		}
+		graph[nodeKey(d)] = d
+		queue = append(queue, edges(d, false)...)
+	}
+	return graph, required
+}
+
+// linkEdges attaches every resolved child to its dependent so the graph
+// carries real parent→child edges
+func (r *Resolver) linkEdges(graph map[string]*Dependency) {
+	for _, d := range graph {
+		edges := make(map[string]*Dependency, len(d.RawDeps))
+		for cn := range d.RawDeps {
+			c := r.child(d, cn)
+			if c == nil {
+				continue
+			}
+			// different specs can resolve to separate nodes of the same version
+			if canonical, ok := graph[nodeKey(c)]; ok {
+				c = canonical
+			}
+			edges[cn] = c
+		}
+		d.Dependencies = edges
+	}
+}
+
+// TopoOrder returns the graph dependencies-first. Packages that form a
+// cycle are kept next to each other; use Cycles to report them.
+func TopoOrder(graph map[string]*Dependency) ([]*Dependency, error) {
+	var order []*Dependency
+	for _, scc := range stronglyConnected(graph) {
+		order = append(order, scc...)
+	}
+	return order, nil
+}
+
+// Cycles returns the strongly connected components of more than one package
+// (or a package depending on itself), in dependencies-first order
+func Cycles(graph map[string]*Dependency) [][]*Dependency {
+	var cycles [][]*Dependency
+	for _, scc := range stronglyConnected(graph) {
+		if len(scc) > 1 {
+			cycles = append(cycles, scc)
+			continue
+		}
+		for _, c := range scc[0].Dependencies {
+			if c == scc[0] {
+				cycles = append(cycles, scc)
+				break
+			}
 		}
 	}
-	// If there is a cycle, append remaining nodes deterministically instead of failing
-	if len(uniq) != len(nodes) {
-		for _, d := range nodes {
-			if !seen[d] {
-				seen[d] = true
-				uniq = append(uniq, d)
+	return cycles
+}
+
+// stronglyConnected runs Tarjan's algorithm; components come out in reverse
+// topological order, i.e. every component after the ones it depends on
+func stronglyConnected(graph map[string]*Dependency) [][]*Dependency {
+	index := make(map[*Dependency]int)
+	low := make(map[*Dependency]int)
+	onStack := make(map[*Dependency]bool)
+	var stack []*Dependency
+	var out [][]*Dependency
+	next := 0
+
+	var connect func(d *Dependency)
+	connect = func(d *Dependency) {
+		index[d] = next
+		low[d] = next
+		next++
+		stack = append(stack, d)
+		onStack[d] = true
+
+		names := make([]string, 0, len(d.Dependencies))
+		for n := range d.Dependencies {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			c := d.Dependencies[n]
+			if _, visited := index[c]; !visited {
+				connect(c)
+				if low[c] < low[d] {
+					low[d] = low[c]
+				}
+			} else if onStack[c] && index[c] < low[d] {
+				low[d] = index[c]
+			}
+		}
+
+		if low[d] == index[d] {
+			var scc []*Dependency
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				scc = append(scc, top)
+				if top == d {
+					break
+				}
 			}
+			sort.Slice(scc, func(i, j int) bool { return scc[i].Name < scc[j].Name })
+			out = append(out, scc)
+		}
+	}
+
+	for _, key := range sortedGraphKeys(graph) {
+		if _, visited := index[graph[key]]; !visited {
+			connect(graph[key])
 		}
 	}
-	return uniq, nil
+	return out
 }
@@ -0,0 +1,285 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"npgo/internal/cas"
+	"npgo/internal/registry"
+)
+
+// spec protocols handled besides plain registry ranges and tags
+const (
+	protoRegistry  = "registry"
+	protoAlias     = "npm"
+	protoGit       = "git"
+	protoTarball   = "tarball"
+	protoFile      = "file"
+	protoLink      = "link"
+	protoWorkspace = "workspace"
+)
+
+var (
+	hostedShorthand = regexp.MustCompile(`^(github|gitlab|bitbucket):`)
+	githubShorthand = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+(#.*)?$`)
+	scpGitURL       = regexp.MustCompile(`^[A-Za-z0-9_.-]+@[A-Za-z0-9_.-]+:`)
+	fullSHA         = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	shortSHA        = regexp.MustCompile(`^[0-9a-f]{7,39}$`)
+)
+
+// specProtocol classifies a dependency spec the way npm-package-arg does
+func specProtocol(spec string) string {
+	switch {
+	case strings.HasPrefix(spec, "npm:"):
+		return protoAlias
+	case strings.HasPrefix(spec, "link:"):
+		return protoLink
+	case strings.HasPrefix(spec, "workspace:"):
+		return protoWorkspace
+	case strings.HasPrefix(spec, "file:"),
+		strings.HasPrefix(spec, "./"), strings.HasPrefix(spec, "../"),
+		strings.HasPrefix(spec, "/"), strings.HasPrefix(spec, "~/"):
+		return protoFile
+	case strings.HasPrefix(spec, "git+"), strings.HasPrefix(spec, "git://"),
+		hostedShorthand.MatchString(spec), scpGitURL.MatchString(spec),
+		githubShorthand.MatchString(spec):
+		return protoGit
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return protoTarball
+	}
+	return protoRegistry
+}
+
+// resolveSource resolves a non-registry spec. The package content is put in
+// the CAS (or linked in place for link:) and its own package.json supplies
+// the version and dependencies.
+func (r *Resolver) resolveSource(name, spec, proto string) (*Dependency, error) {
+	switch proto {
+	case protoAlias:
+		target := strings.TrimPrefix(spec, "npm:")
+		realName, rng := target, "latest"
+		if at := strings.LastIndex(target, "@"); at > 0 {
+			realName, rng = target[:at], target[at+1:]
+		}
+		md, err := r.metadata(realName, normalizeVersion(rng))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+		}
+		dep := newDependency(name, spec, md)
+		dep.Source = "npm:" + realName + "@" + md.Version
+		return dep, nil
+
+	case protoGit:
+		url, ref := gitRemote(spec)
+		if registry.Mode() == registry.Offline && !strings.HasPrefix(url, "file://") {
+			return nil, fmt.Errorf("%w: cannot reach git remote %s", registry.ErrOffline, url)
+		}
+		sha, err := gitResolveRef(url, ref)
+		if err != nil {
+			return nil, err
+		}
+		// a short SHA is only known to the checkout; lock the full one
+		dir, sha, err := gitCheckout(url, sha)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		hash, casPath, err := cas.ImportDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		source := url
+		if !strings.HasPrefix(url, "git://") && !scpGitURL.MatchString(url) {
+			source = "git+" + url
+		}
+		return localDependency(name, spec, casPath, hash, source+"#"+sha)
+
+	case protoTarball:
+		stream, err := r.tarballs.Tarball(name, "", spec)
+		if err != nil {
+			return nil, err
+		}
+		defer stream.Close()
+		hash, casPath, err := cas.ImportTarball(stream, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", spec, err)
+		}
+		return localDependency(name, spec, casPath, hash, spec)
+
+	case protoFile:
+		rel := strings.TrimPrefix(spec, "file:")
+		path := expandHome(rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", spec, err)
+		}
+		var hash, casPath string
+		if info.IsDir() {
+			hash, casPath, err = cas.ImportDir(path)
+		} else {
+			f, openErr := os.Open(path)
+			if openErr != nil {
+				return nil, openErr
+			}
+			hash, casPath, err = cas.ImportTarball(f, "")
+			f.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", spec, err)
+		}
+		return localDependency(name, spec, casPath, hash, "file:"+filepath.ToSlash(rel))
+
+	case protoLink:
+		rel := strings.TrimPrefix(spec, "link:")
+		abs, err := filepath.Abs(expandHome(rel))
+		if err != nil {
+			return nil, err
+		}
+		dep, err := localDependency(name, spec, abs, "", "link:"+filepath.ToSlash(rel))
+		if err != nil {
+			return nil, err
+		}
+		// linked packages manage their own dependencies
+		dep.RawDeps = map[string]string{}
+		dep.declared = dep.RawDeps
+		dep.PeerDeps = nil
+		dep.Linked = true
+		return dep, nil
+
+	case protoWorkspace:
+		return r.resolveWorkspace(name, spec)
+	}
+	return nil, fmt.Errorf("unsupported spec %q", spec)
+}
+
+// localDependency builds a dependency from a package directory's package.json
+func localDependency(name, spec, dir, hash, source string) (*Dependency, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("no package.json in %s: %w", spec, err)
+	}
+	var md registry.PackageMetadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("invalid package.json in %s: %w", spec, err)
+	}
+	if md.Version == "" {
+		md.Version = "0.0.0"
+	}
+	dep := newDependency(name, spec, &md)
+	dep.TarballURL = ""
+	dep.LocalPath = dir
+	dep.StoreHash = hash
+	dep.Source = source
+	return dep, nil
+}
+
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
+
+// gitRemote turns a git spec into a clonable URL and a ref ("" = HEAD)
+func gitRemote(spec string) (string, string) {
+	url, ref := spec, ""
+	if i := strings.Index(spec, "#"); i >= 0 {
+		url, ref = spec[:i], spec[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(url, "github:"):
+		url = "https://github.com/" + strings.TrimPrefix(url, "github:") + ".git"
+	case strings.HasPrefix(url, "gitlab:"):
+		url = "https://gitlab.com/" + strings.TrimPrefix(url, "gitlab:") + ".git"
+	case strings.HasPrefix(url, "bitbucket:"):
+		url = "https://bitbucket.org/" + strings.TrimPrefix(url, "bitbucket:") + ".git"
+	case strings.HasPrefix(url, "git+"):
+		url = strings.TrimPrefix(url, "git+")
+	case githubShorthand.MatchString(spec):
+		url = "https://github.com/" + url + ".git"
+	}
+	return url, ref
+}
+
+// gitResolveRef pins a branch, tag or HEAD to a commit SHA
+func gitResolveRef(url, ref string) (string, error) {
+	if fullSHA.MatchString(ref) {
+		return ref, nil
+	}
+	query := ref
+	if query == "" {
+		query = "HEAD"
+	}
+	out, err := exec.Command("git", "ls-remote", "--", url, query, query+"^{}").Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote %s failed: %w", url, err)
+	}
+	sha := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		// annotated tags: prefer the peeled commit
+		if strings.HasSuffix(fields[1], "^{}") || sha == "" {
+			sha = fields[0]
+		}
+	}
+	if sha == "" {
+		// not a ref name: maybe an abbreviated commit, which gitCheckout expands
+		if shortSHA.MatchString(ref) {
+			return ref, nil
+		}
+		return "", fmt.Errorf("git ref %q not found in %s", query, url)
+	}
+	return sha, nil
+}
+
+// gitCheckout fetches a single commit into a temporary worktree without .git
+// and returns it with the full SHA of the commit; rev may be abbreviated
+func gitCheckout(url, rev string) (string, string, error) {
+	dir, err := os.MkdirTemp("", "npgo-git-*")
+	if err != nil {
+		return "", "", err
+	}
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	_, err = run("init", "--quiet")
+	if err == nil {
+		if _, err = run("fetch", "--quiet", "--depth", "1", "--", url, rev); err != nil {
+			// servers that refuse fetching by SHA, and abbreviated SHAs,
+			// need the full history
+			_, err = run("fetch", "--quiet", "--", url)
+		}
+	}
+	if err == nil {
+		_, err = run("checkout", "--quiet", rev)
+	}
+	sha := ""
+	if err == nil {
+		sha, err = run("rev-parse", "HEAD")
+		if err == nil && !fullSHA.MatchString(sha) {
+			err = fmt.Errorf("git rev-parse HEAD: unexpected output %q", sha)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	_ = os.RemoveAll(filepath.Join(dir, ".git"))
+	return dir, sha, nil
+}
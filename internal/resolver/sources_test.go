@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"npgo/internal/registry"
+)
+
+// packTarball builds an npm-style tarball holding only package/package.json
+func packTarball(t *testing.T, manifest string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0644, Size: int64(len(manifest))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTarballURLUsesTarballSource(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mem := registry.NewMemory()
+	md := registry.PackageMetadata{Name: "c", Version: "1.0.0"}
+	md.Dist.Tarball = "https://example.invalid/c-1.0.0.tgz"
+	if err := mem.Add(md, packTarball(t, `{"name":"c","version":"1.0.0","dependencies":{"d":"^1"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Add(registry.PackageMetadata{Name: "d", Version: "1.4.0"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver()
+	r.SetMetadataSource(mem)
+	r.SetTarballSource(mem)
+	root := map[string]string{"c": md.Dist.Tarball}
+	if _, err := r.BuildGraph(root); err != nil {
+		t.Fatal(err)
+	}
+	c := r.Lookup("c", md.Dist.Tarball)
+	if c == nil || c.Resolved != "1.0.0" || c.Source != md.Dist.Tarball || c.LocalPath == "" {
+		t.Fatalf("Lookup(c) = %+v", c)
+	}
+	if d := c.Dependencies["d"]; d == nil || d.Resolved != "1.4.0" {
+		t.Errorf("c's dependency d = %+v, want 1.4.0", d)
+	}
+}
+
+func TestGitShortSHAIsLockedInFull(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.invalid"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+	if err := os.WriteFile(filepath.Join(repo, "package.json"), []byte(`{"name":"g","version":"1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "package.json")
+	git("commit", "--quiet", "-m", "init")
+	sha := git("rev-parse", "HEAD")
+
+	spec := "git+file://" + repo + "#" + sha[:7]
+	r := NewResolver()
+	r.SetMetadataSource(registry.NewMemory())
+	if _, err := r.BuildGraph(map[string]string{"g": spec}); err != nil {
+		t.Fatal(err)
+	}
+	g := r.Lookup("g", spec)
+	if g == nil || g.Resolved != "1.0.0" {
+		t.Fatalf("Lookup(g) = %+v", g)
+	}
+	if want := "git+file://" + repo + "#" + sha; g.Source != want {
+		t.Errorf("Source = %q, want %q", g.Source, want)
+	}
+}
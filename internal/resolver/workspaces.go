@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"npgo/internal/registry"
+	"npgo/internal/semver"
+	"npgo/internal/workspace"
+)
+
+// SetWorkspaces registers the monorepo members that dependency edges may
+// resolve to, either through the workspace: protocol or a satisfied range
+func (r *Resolver) SetWorkspaces(members []*workspace.Member) {
+	r.workspaces = make(map[string]*workspace.Member, len(members))
+	for _, m := range members {
+		r.workspaces[m.Name] = m
+	}
+}
+
+// WithWorkspaces returns specs plus every member not already listed, so all
+// members and their external dependencies resolve into one graph
+func (r *Resolver) WithWorkspaces(specs map[string]string) map[string]string {
+	if len(r.workspaces) == 0 {
+		return specs
+	}
+	out := make(map[string]string, len(specs)+len(r.workspaces))
+	for n, s := range specs {
+		out[n] = s
+	}
+	for name := range r.workspaces {
+		if _, ok := out[name]; !ok {
+			out[name] = "workspace:*"
+		}
+	}
+	return out
+}
+
+// workspaceSatisfies reports whether a plain registry range is met by a member
+func (r *Resolver) workspaceSatisfies(name, spec string) bool {
+	m, ok := r.workspaces[name]
+	if !ok {
+		return false
+	}
+	return semver.Satisfies(m.Version, spec)
+}
+
+// resolveWorkspace links a member; "workspace:*", "workspace:^" and
+// "workspace:~" accept the current version, anything else must satisfy it
+func (r *Resolver) resolveWorkspace(name, spec string) (*Dependency, error) {
+	m, ok := r.workspaces[name]
+	if !ok {
+		return nil, fmt.Errorf("no workspace package named %s", name)
+	}
+	switch rng := strings.TrimPrefix(spec, "workspace:"); rng {
+	case "*", "^", "~", "":
+	default:
+		if !semver.Satisfies(m.Version, rng) {
+			return nil, fmt.Errorf("workspace %s@%s does not satisfy %s", name, m.Version, spec)
+		}
+	}
+	dep := newDependency(name, spec, &registry.PackageMetadata{
+		Name:         name,
+		Version:      m.Version,
+		Dependencies: MemberSpecs(m),
+	})
+	dep.LocalPath = m.Dir
+	dep.Source = "workspace:" + m.RelDir
+	dep.Engines = m.Manifest.EngineRanges()
+	dep.Linked = true
+	return dep, nil
+}
+
+// memberSpecs returns a member's dependencies and devDependencies with local
+// paths rebased onto the workspace root
+func MemberSpecs(m *workspace.Member) map[string]string {
+	deps := m.Manifest.GetDependencies()
+	for dn, ds := range deps {
+		deps[dn] = rebaseLocalSpec(ds, m.RelDir)
+	}
+	return deps
+}
+
+// rebaseLocalSpec makes a member's relative file:/link: spec relative to the
+// workspace root, where resolution runs
+func rebaseLocalSpec(spec, relDir string) string {
+	proto := specProtocol(spec)
+	if proto != protoFile && proto != protoLink {
+		return spec
+	}
+	prefix := proto + ":"
+	p := strings.TrimPrefix(spec, prefix)
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "~/") {
+		return spec
+	}
+	return prefix + filepath.ToSlash(filepath.Join(filepath.FromSlash(relDir), filepath.FromSlash(p)))
+}
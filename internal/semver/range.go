@@ -0,0 +1,297 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// comparator is a single "<op> <version>" test; a nil version matches anything.
+type comparator struct {
+	op string
+	v  *Version
+}
+
+// Range is a parsed npm range: a union (||) of comparator sets.
+type Range struct {
+	raw  string
+	sets [][]comparator
+}
+
+// operators followed by whitespace (">= 1.2.3") are glued to their version
+var opSpace = regexp.MustCompile(`(~>|~|\^|>=|<=|>|<|=)\s+`)
+
+// ParseRange parses every npm range form: exact versions, x-ranges, caret,
+// tilde, hyphen ranges, comparator sets and || unions.
+func ParseRange(s string) (*Range, error) {
+	r := &Range{raw: s}
+	for _, part := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// ValidRange reports whether s parses as a range.
+func ValidRange(s string) bool {
+	_, err := ParseRange(s)
+	return err == nil
+}
+
+func (r *Range) String() string { return r.raw }
+
+// Satisfies reports whether v is inside the range. Prerelease versions only
+// match a comparator set that names a prerelease on the same major.minor.patch.
+func (r *Range) Satisfies(v *Version) bool {
+	for _, set := range r.sets {
+		if testSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfies parses version and rng and tests them; parse errors yield false.
+func Satisfies(version, rng string) bool {
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+	r, err := ParseRange(rng)
+	if err != nil {
+		return false
+	}
+	return r.Satisfies(v)
+}
+
+// MaxSatisfying returns the highest entry of versions inside r, or "".
+func MaxSatisfying(versions []string, r *Range) string {
+	var best *Version
+	bestRaw := ""
+	for _, s := range versions {
+		v, err := Parse(s)
+		if err != nil || !r.Satisfies(v) {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, bestRaw = v, s
+		}
+	}
+	return bestRaw
+}
+
+func testSet(set []comparator, v *Version) bool {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if c.v != nil && len(c.v.Prerelease) > 0 && c.v.sameTuple(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparator) test(v *Version) bool {
+	if c.v == nil {
+		return true
+	}
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "", "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	if s == "" {
+		return []comparator{{}}, nil
+	}
+	if f := strings.Fields(s); len(f) == 3 && f[1] == "-" {
+		return hyphenRange(f[0], f[2])
+	}
+	var set []comparator
+	for _, tok := range strings.Fields(opSpace.ReplaceAllString(s, "$1")) {
+		op, rest := splitOperator(tok)
+		p, err := parsePartial(rest)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, expand(op, p)...)
+	}
+	return set, nil
+}
+
+func splitOperator(tok string) (string, string) {
+	for _, op := range []string{"~>", "~", "^", ">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(tok, op) {
+			if op == "~>" {
+				return "~", tok[2:]
+			}
+			return op, tok[len(op):]
+		}
+	}
+	return "", tok
+}
+
+// partial is a possibly incomplete version such as "1", "1.2.x" or "*";
+// n counts the leading numeric components that were given.
+type partial struct {
+	n                   int
+	major, minor, patch uint64
+	pre                 []string
+}
+
+func parsePartial(s string) (partial, error) {
+	var p partial
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		p.pre = strings.Split(s[i+1:], ".")
+		for _, id := range p.pre {
+			if id == "" {
+				return p, fmt.Errorf("invalid prerelease in %q", s)
+			}
+		}
+		s = s[:i]
+	}
+	if s == "" {
+		return p, nil
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("too many version components in %q", s)
+	}
+	nums := []*uint64{&p.major, &p.minor, &p.patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("invalid version component %q", part)
+		}
+		*nums[i] = n
+		p.n = i + 1
+	}
+	if p.n < 3 {
+		p.pre = nil
+	}
+	return p, nil
+}
+
+func ver(major, minor, patch uint64, pre ...string) *Version {
+	return &Version{Major: major, Minor: minor, Patch: patch, Prerelease: pre}
+}
+
+// floor is the lowest version matched by p.
+func (p partial) floor() *Version {
+	return ver(p.major, p.minor, p.patch, p.pre...)
+}
+
+// ceiling is the exclusive upper bound of an x-range partial ("1.2" → 1.3.0-0).
+func (p partial) ceiling() *Version {
+	if p.n == 1 {
+		return ver(p.major+1, 0, 0, "0")
+	}
+	return ver(p.major, p.minor+1, 0, "0")
+}
+
+var never = []comparator{{op: "<", v: ver(0, 0, 0, "0")}}
+
+func expand(op string, p partial) []comparator {
+	if p.n == 0 {
+		if op == "<" || op == ">" {
+			return never
+		}
+		return []comparator{{}}
+	}
+	switch op {
+	case "", "=":
+		if p.n == 3 {
+			return []comparator{{op: "=", v: p.floor()}}
+		}
+		return []comparator{{op: ">=", v: p.floor()}, {op: "<", v: p.ceiling()}}
+	case "~":
+		if p.n == 1 {
+			return []comparator{{op: ">=", v: p.floor()}, {op: "<", v: ver(p.major+1, 0, 0, "0")}}
+		}
+		return []comparator{{op: ">=", v: p.floor()}, {op: "<", v: ver(p.major, p.minor+1, 0, "0")}}
+	case "^":
+		var upper *Version
+		switch {
+		case p.major > 0 || p.n == 1:
+			upper = ver(p.major+1, 0, 0, "0")
+		case p.minor > 0 || p.n == 2:
+			upper = ver(0, p.minor+1, 0, "0")
+		default:
+			upper = ver(0, 0, p.patch+1, "0")
+		}
+		return []comparator{{op: ">=", v: p.floor()}, {op: "<", v: upper}}
+	case ">":
+		if p.n == 3 {
+			return []comparator{{op: ">", v: p.floor()}}
+		}
+		c := p.ceiling()
+		c.Prerelease = nil
+		return []comparator{{op: ">=", v: c}}
+	case ">=":
+		return []comparator{{op: ">=", v: p.floor()}}
+	case "<":
+		if p.n == 3 {
+			return []comparator{{op: "<", v: p.floor()}}
+		}
+		return []comparator{{op: "<", v: ver(p.major, p.minor, 0, "0")}}
+	case "<=":
+		if p.n == 3 {
+			return []comparator{{op: "<=", v: p.floor()}}
+		}
+		return []comparator{{op: "<", v: p.ceiling()}}
+	}
+	return never
+}
+
+func hyphenRange(from, to string) ([]comparator, error) {
+	lo, err := parsePartial(from)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parsePartial(to)
+	if err != nil {
+		return nil, err
+	}
+	var set []comparator
+	if lo.n > 0 {
+		set = append(set, comparator{op: ">=", v: lo.floor()})
+	}
+	switch {
+	case hi.n == 3:
+		set = append(set, comparator{op: "<=", v: hi.floor()})
+	case hi.n > 0:
+		set = append(set, comparator{op: "<", v: hi.ceiling()})
+	}
+	if len(set) == 0 {
+		set = []comparator{{}}
+	}
+	return set, nil
+}
@@ -0,0 +1,133 @@
+package semver
+
+import "testing"
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		rng, version string
+		want         bool
+	}{
+		// exact and x-ranges
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"=1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{"", "0.0.1", true},
+		{"*", "9.9.9", true},
+		{"latest-ish", "1.0.0", false},
+		{"1", "1.9.0", true},
+		{"1", "2.0.0", false},
+		{"1.x", "1.4.2", true},
+		{"1.2.x", "1.2.9", true},
+		{"1.2.x", "1.3.0", false},
+		{"1.2", "1.2.0", true},
+
+		// caret
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.9.9", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "1.2.2", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.x", "0.9.0", true},
+		{"^1.x", "1.0.0", true},
+
+		// tilde
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2", "1.2.0", true},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"~> 1.2.3", "1.2.5", true},
+
+		// comparators, hyphens and unions
+		{">=1.2.3", "1.2.3", true},
+		{">1.2.3", "1.2.3", false},
+		{"<2", "1.99.0", true},
+		{"<2", "2.0.0", false},
+		{"<=1.2", "1.2.9", true},
+		{">= 1.0.0 < 2.0.0", "1.5.0", true},
+		{">=1.0.0 <2.0.0", "2.0.0", false},
+		{"1.2.3 - 2.3.4", "2.3.4", true},
+		{"1.2.3 - 2.3.4", "2.3.5", false},
+		{"1.2 - 2.3", "2.3.9", true},
+		{"1.2 - 2", "2.9.0", true},
+		{"1.2 - 2", "1.1.9", false},
+		{"^1.0.0 || ^3.0.0", "3.1.0", true},
+		{"^1.0.0 || ^3.0.0", "2.1.0", false},
+		{"1.x || >=2.5.0 || 5.0.0 - 7.2.3", "2.6.0", true},
+
+		// prereleases only match a set naming the same tuple
+		{"^1.2.3", "1.3.0-beta.1", false},
+		{"^1.2.3-beta.1", "1.2.3-beta.2", true},
+		{"^1.2.3-beta.1", "1.2.4-beta.1", false},
+		{">=1.0.0-rc.1", "1.0.0-rc.2", true},
+		{">=1.0.0-rc.1", "1.0.0", true},
+		{"*", "1.0.0-alpha", false},
+		{"1.0.0-alpha", "1.0.0-alpha", true},
+
+		// build metadata is ignored
+		{"1.2.3", "1.2.3+build.5", true},
+	}
+	for _, tt := range tests {
+		if got := Satisfies(tt.version, tt.rng); got != tt.want {
+			t.Errorf("Satisfies(%q, %q) = %v, want %v", tt.version, tt.rng, got, tt.want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, rng := range []string{"1.2.3.4", ">=a.b.c", ">=>1", "not-a-range", "1.2.3 -", "~1.2.3-", "1.2.3-beta..1"} {
+		if ValidRange(rng) {
+			t.Errorf("ValidRange(%q) = true, want false", rng)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0-rc.1", "2.0.0", "2.1.0", "3.0.0-beta.2"}
+	tests := []struct {
+		rng, want string
+	}{
+		{"^1.0.0", "1.10.0"},
+		{"~1.2.0", "1.2.0"},
+		{">=2.0.0-rc.1 <2.0.0", "2.0.0-rc.1"},
+		{"^2", "2.1.0"},
+		{"*", "2.1.0"},
+		{"^3.0.0-beta.1", "3.0.0-beta.2"},
+		{"^4", ""},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.rng)
+		if err != nil {
+			t.Fatalf("ParseRange(%q): %v", tt.rng, err)
+		}
+		if got := MaxSatisfying(versions, r); got != tt.want {
+			t.Errorf("MaxSatisfying(%q) = %q, want %q", tt.rng, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version (major.minor.patch[-pre][+build])
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+	raw        string
+}
+
+// Parse parses a full version. A leading "v" or "=" and surrounding
+// whitespace are tolerated, matching npm's loose handling of packuments.
+func Parse(s string) (*Version, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "=")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return nil, fmt.Errorf("invalid version %q", raw)
+	}
+
+	v := &Version{raw: raw}
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.Prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+		for _, id := range v.Prerelease {
+			if id == "" {
+				return nil, fmt.Errorf("invalid prerelease in version %q", raw)
+			}
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid version %q", raw)
+	}
+	nums := make([]uint64, 3)
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", raw)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// Valid reports whether s is a full, exact version.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// Original returns the string the version was parsed from.
+func (v *Version) Original() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	return v.String()
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1. Build metadata is ignored.
+func (v *Version) Compare(o *Version) int {
+	if c := cmpUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmpUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmpUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func (v *Version) sameTuple(o *Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
+
+// Compare parses and compares two version strings; invalid versions sort first.
+func Compare(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.Compare(vb)
+}
+
+func cmpUint(a, b uint64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease follows semver §11: a version without prerelease has
+// higher precedence; numeric identifiers sort below alphanumeric ones.
+func comparePrerelease(a, b []string) int {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	if len(b) == 0 {
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpUint(uint64(len(a)), uint64(len(b)))
+}
+
+func compareIdentifier(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpUint(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,147 @@
+package workspace
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"npgo/internal/packagejson"
+)
+
+// Member is one package of a monorepo
+type Member struct {
+	Name    string
+	Version string
+	// Dir is the absolute package directory; RelDir is relative to the root
+	Dir      string
+	RelDir   string
+	Manifest *packagejson.PackageJSON
+}
+
+// Discover expands the root package.json workspace globs ("!" negates) and
+// reads every member's package.json. It returns nil when the root declares
+// no workspaces.
+func Discover(rootDir string, root *packagejson.PackageJSON) ([]*Member, error) {
+	patterns := root.WorkspacePatterns()
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	rootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]bool)
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(pattern, "!")), "./")
+		matches, err := expand(rootDir, strings.TrimSuffix(pattern, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+		for _, rel := range matches {
+			dirs[rel] = !negate
+		}
+	}
+
+	members := make([]*Member, 0, len(dirs))
+	byName := make(map[string]*Member)
+	for rel, keep := range dirs {
+		if !keep {
+			continue
+		}
+		dir := filepath.Join(rootDir, filepath.FromSlash(rel))
+		manifestPath := filepath.Join(dir, "package.json")
+		if _, err := os.Stat(manifestPath); err != nil {
+			continue
+		}
+		pkg, err := packagejson.Read(manifestPath)
+		if err != nil {
+			return nil, fmt.Errorf("workspace %s: %w", rel, err)
+		}
+		if pkg.Name == "" {
+			return nil, fmt.Errorf("workspace %s has no name in package.json", rel)
+		}
+		if prev, ok := byName[pkg.Name]; ok {
+			return nil, fmt.Errorf("workspace name %s is used by both %s and %s", pkg.Name, prev.RelDir, rel)
+		}
+		version := pkg.Version
+		if version == "" {
+			version = "0.0.0"
+		}
+		m := &Member{Name: pkg.Name, Version: version, Dir: dir, RelDir: rel, Manifest: pkg}
+		byName[pkg.Name] = m
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].RelDir < members[j].RelDir })
+	return members, nil
+}
+
+// expand returns the directories (relative, slash separated) matching a glob;
+// "**" matches any number of directories
+func expand(rootDir, pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(filepath.Join(rootDir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		var out []string
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && info.IsDir() {
+				rel, _ := filepath.Rel(rootDir, m)
+				out = append(out, filepath.ToSlash(rel))
+			}
+		}
+		return out, nil
+	}
+
+	segs := strings.Split(pattern, "/")
+	var out []string
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if name := d.Name(); path != rootDir && (name == "node_modules" || strings.HasPrefix(name, ".")) {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(rootDir, path)
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		ok, err := matchSegments(segs, strings.Split(rel, "/"))
+		if err != nil {
+			return err
+		}
+		if ok {
+			out = append(out, rel)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func matchSegments(pattern, path []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(path) == 0, nil
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if ok, err := matchSegments(pattern[1:], path[i:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(path) == 0 {
+		return false, nil
+	}
+	ok, err := filepath.Match(pattern[0], path[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(pattern[1:], path[1:])
+}